@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/edgerun/telemd/internal/telemd"
+	"os"
+)
+
+const usage = `usage: telemd [flags] [config validate [<file>]]`
+
+// runCommand runs the subcommand given by the arguments, and returns the exit code.
+func runCommand(configPath string, args []string) int {
+	if len(args) < 2 || len(args) > 3 || args[0] != "config" || args[1] != "validate" {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	if len(args) == 3 {
+		configPath = args[2]
+	}
+	return validateConfig(configPath)
+}
+
+// validateConfig reads the config file at the given path like telemd does on startup, including the overrides of the
+// environment, and prints the invalid values.
+func validateConfig(path string) int {
+	if _, err := os.Stat(path); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	_, err := telemd.LoadConfig(path)
+	if err == nil {
+		fmt.Println(path, "is valid")
+		return 0
+	}
+
+	var errs telemd.ConfigErrors
+	if errors.As(err, &errs) {
+		fmt.Fprintln(os.Stderr, path, "is invalid:")
+		for _, err := range errs {
+			fmt.Fprintln(os.Stderr, " ", err)
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return 1
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"github.com/edgerun/telemd/internal/env"
 	"github.com/edgerun/telemd/internal/redis"
 	"github.com/edgerun/telemd/internal/telem"
@@ -11,31 +12,114 @@ import (
 	"syscall"
 )
 
-func loadConfig() *telemd.Config {
-	cfg := telemd.NewDefaultConfig()
-	cfg.LoadFromEnvironment(env.OsEnv) // load os env first to get potential telemd_nodename
+// handleConnectionState starts and stops the command server and the telemetry reporter as the state of the redis
+// connection changes, until the client is closed. If the central config is enabled, it is read once connected.
+func handleConnectionState(client *redis.ReconnectingClient, cfg *telemd.Config, daemon *telemd.Daemon,
+	commandServer *telemd.RedisCommandServer, telemetryReporter *telemd.RedisReporter, requestReload func()) {
+	for {
+		state := <-client.ConnectionState
+		if cfg.CentralConfig && (state == redis.Connected || state == redis.Recovered) {
+			// the central config may have changed while disconnected
+			requestReload()
+		}
 
-	if _, err := os.Stat(telemd.DefaultConfigPath); err == nil {
-		log.Println("reading config from", telemd.DefaultConfigPath)
+		switch state {
+		case redis.Connected:
+			// the tickers are paused if the connection to a previous redis URL had failed
+			daemon.UnpauseTickers()
+			go commandServer.Run()
+			go telemetryReporter.Run()
+			err := commandServer.UpdateNodeInfo()
+			if err != nil {
+				log.Fatal("error initializing node info", err)
+			}
+		case redis.Failed:
+			daemon.PauseTickers()
+			// the command server resubscribes on its own once redis is reachable again
+			go telemetryReporter.Stop()
+			go client.Client.Ping()
+		case redis.Recovered:
+			daemon.SelfStats().Reconnected()
+			daemon.UnpauseTickers()
+			go commandServer.Run() // no-op if the server is still running
+			go telemetryReporter.Run()
+		default:
+			return
+		}
+	}
+}
 
-		iniEnv, err := env.NewIniEnvironment(telemd.DefaultConfigPath)
-		if err == nil {
-			cfg.LoadFromEnvironment(iniEnv)
+// reload reads the config again and applies it to the daemon. If the redis URL has changed, it connects to the new URL
+// and returns the new client, otherwise the given one.
+func reload(path string, cfg *telemd.Config, client *redis.ReconnectingClient, daemon *telemd.Daemon,
+	commandServer *telemd.RedisCommandServer, telemetryReporter *telemd.RedisReporter,
+	requestReload func()) *redis.ReconnectingClient {
+	var central []env.Environment
+	if cfg.CentralConfig {
+		var err error
+		// without the central config, the reload would revert its settings
+		if central, err = telemd.ReadCentralConfig(client.Client, cfg.NodeName); err != nil {
+			log.Println("not reloading config:", err)
+			return client
 		}
+	}
 
-		iniNodeEnv, err := env.NewIniSectionEnvironment(telemd.DefaultConfigPath, cfg.NodeName)
-		if err == nil {
-			cfg.LoadFromEnvironment(iniNodeEnv)
+	newCfg, err := telemd.LoadConfig(path, central...)
+	if err != nil {
+		log.Println("not reloading config:", err)
+		return client
+	}
+
+	var newClient *redis.ReconnectingClient
+	if newCfg.Redis != cfg.Redis {
+		newClient, err = redis.NewReconnectingClientFromUrl(newCfg.Redis.URL, newCfg.Redis.RetryBackoff)
+		if err != nil {
+			log.Println("not changing redis URL:", err)
+			newCfg.Redis = cfg.Redis
 		}
+	}
 
-		cfg.LoadFromEnvironment(env.OsEnv) // overwrite ini values with os env as per specified behavior
+	daemon.Reload(newCfg)
+	if newClient == nil {
+		return client
 	}
 
-	return cfg
+	log.Println("connecting to", newCfg.Redis.URL)
+	commandServer.Stop()
+	if !client.IsRetrying() {
+		_ = commandServer.RemoveNodeInfo()
+	}
+	client.Close()
+	telemetryReporter.Stop()
+
+	commandServer.SetClient(newClient.Client)
+	telemetryReporter.SetClient(newClient.Client)
+	go handleConnectionState(newClient, cfg, daemon, commandServer, telemetryReporter, requestReload)
+	go newClient.Client.Ping()
+
+	return newClient
 }
 
 func main() {
-	cfg := loadConfig()
+	listInstruments := flag.Bool("list-instruments", false, "list the available instruments and exit")
+	configPath := flag.String("config", telemd.FindConfigPath(), "the config file, read as YAML if it ends with .yaml or .yml")
+	flag.Parse()
+
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(*configPath, flag.Args()))
+	}
+
+	cfg, err := telemd.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *listInstruments {
+		if err := telemd.ListInstruments(os.Stdout, cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	telem.NodeName = cfg.NodeName
 	hostname, _ := os.Hostname()
@@ -49,36 +133,45 @@ func main() {
 	commandServer := telemd.NewRedisCommandServer(daemon, reconnectingClient.Client)
 	telemetryReporter := telemd.NewRedisReporter(daemon, reconnectingClient.Client)
 
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	// config changes are handled like a SIGHUP, but through their own channel so that they cannot crowd out a SIGTERM
+	reloads := make(chan struct{}, 1)
+	requestReload := func() {
+		select {
+		case reloads <- struct{}{}:
+		default: // a reload is pending anyway
+		}
+	}
+	commandServer.OnReload(requestReload)
+
+	go handleConnectionState(reconnectingClient, cfg, daemon, commandServer, telemetryReporter, requestReload)
+	// initiate redis connection by sending a PING
+	go reconnectingClient.Client.Ping()
+
 	go func() {
+		watching := make(chan struct{})
+		defer close(watching)
+		if cfg.WatchConfig {
+			err := telemd.WatchConfigFile(*configPath, watching, requestReload)
+			if err != nil {
+				log.Println("not watching config file:", err)
+			}
+		}
+
+	loop:
 		for {
-			state := <-reconnectingClient.ConnectionState
-			switch state {
-			case redis.Connected:
-				go commandServer.Run()
-				go telemetryReporter.Run()
-				err := commandServer.UpdateNodeInfo()
-				if err != nil {
-					log.Fatal("error initializing node info", err)
+			select {
+			case sig := <-sigs:
+				if sig != syscall.SIGHUP {
+					break loop
 				}
-			case redis.Failed:
-				daemon.PauseTickers()
-				commandServer.Stop()
-				go telemetryReporter.Stop()
-				go reconnectingClient.Client.Ping()
-			case redis.Recovered:
-				daemon.UnpauseTickers()
-				go commandServer.Run()
-				go telemetryReporter.Run()
-			default:
-				return
+			case <-reloads:
 			}
+			log.Println("reloading config")
+			reconnectingClient = reload(*configPath, cfg, reconnectingClient, daemon, commandServer, telemetryReporter, requestReload)
 		}
-	}()
-
-	go func() {
-		sigs := make(chan os.Signal, 1)
-		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-		<-sigs
 
 		log.Println("stopping command server")
 		commandServer.Stop()
@@ -100,9 +193,6 @@ func main() {
 		log.Print("all resources closed")
 	}()
 
-	// initiate redis connection by sending a PING
-	go reconnectingClient.Client.Ping()
-
 	log.Println("running daemon")
 	daemon.Run() // blocks until everything has shut down after daemon.Stop()
 	log.Println("exiting")
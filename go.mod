@@ -7,4 +7,5 @@ require github.com/go-redis/redis/v7 v7.2.0
 require (
 	github.com/smartystreets/goconvey v1.6.4 // indirect
 	gopkg.in/ini.v1 v1.56.0
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,185 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const DefaultSocket = "/var/run/docker.sock"
+
+// Client is a minimal Docker Engine API client that talks HTTP over the docker unix socket.
+type Client struct {
+	socket     string
+	httpClient *http.Client
+}
+
+type Container struct {
+	Id     string            `json:"id"`
+	Name   string            `json:"name"`
+	Image  string            `json:"image"`
+	Labels map[string]string `json:"labels"`
+	Pid    int               `json:"pid"`
+}
+
+type Event struct {
+	Type   string
+	Action string
+	Id     string
+	Time   time.Time
+}
+
+// containerJson is the subset of the /containers/{id}/json response we are interested in.
+type containerJson struct {
+	Id     string
+	Name   string
+	Config struct {
+		Image  string
+		Labels map[string]string
+	}
+	State struct {
+		Pid int
+	}
+}
+
+type eventJson struct {
+	Type   string
+	Action string
+	Actor  struct {
+		ID string
+	}
+	TimeNano int64 `json:"timeNano"`
+}
+
+func NewClient(socket string) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}
+
+	return &Client{
+		socket:     socket,
+		httpClient: &http.Client{Transport: transport},
+	}
+}
+
+// ContainerIds returns the ids of all running containers.
+func (c *Client) ContainerIds(ctx context.Context) ([]string, error) {
+	var containers []struct{ Id string }
+
+	if err := c.getJson(ctx, "/containers/json", &containers); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(containers))
+	for i, container := range containers {
+		ids[i] = container.Id
+	}
+	return ids, nil
+}
+
+// Inspect returns the metadata of the container with the given (full or short) id.
+func (c *Client) Inspect(ctx context.Context, id string) (*Container, error) {
+	var data containerJson
+
+	if err := c.getJson(ctx, "/containers/"+url.PathEscape(id)+"/json", &data); err != nil {
+		return nil, err
+	}
+
+	return &Container{
+		Id:     data.Id,
+		Name:   strings.TrimPrefix(data.Name, "/"),
+		Image:  data.Config.Image,
+		Labels: data.Config.Labels,
+		Pid:    data.State.Pid,
+	}, nil
+}
+
+// Events streams container events into the returned channel until the context is cancelled or the stream breaks.
+// The error channel receives exactly one value once the stream has ended, after the event channel was closed.
+func (c *Client) Events(ctx context.Context) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		errs <- c.streamEvents(ctx, events)
+	}()
+
+	return events, errs
+}
+
+func (c *Client) streamEvents(ctx context.Context, events chan<- Event) error {
+	defer close(events)
+
+	query := url.Values{}
+	query.Set("filters", `{"type":["container"]}`)
+
+	response, err := c.get(ctx, "/events?"+query.Encode())
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var data eventJson
+		if err := decoder.Decode(&data); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		event := Event{
+			Type:   data.Type,
+			Action: data.Action,
+			Id:     data.Actor.ID,
+			Time:   time.Unix(0, data.TimeNano),
+		}
+
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *Client) get(ctx context.Context, path string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(response.Body)
+		_ = response.Body.Close()
+		return nil, fmt.Errorf("docker api %s returned %s: %s", path, response.Status, strings.TrimSpace(string(body)))
+	}
+
+	return response, nil
+}
+
+func (c *Client) getJson(ctx context.Context, path string, v interface{}) error {
+	response, err := c.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	return json.NewDecoder(response.Body).Decode(v)
+}
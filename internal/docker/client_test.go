@@ -0,0 +1,139 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testContainerId = "dc65d1e5672961e7191260dec3dd532ad346719ea3ae23035e3b560867bd1183"
+
+// newFakeDaemon starts an HTTP server that listens on a unix socket in a temporary directory and returns the socket
+// path as well as a function to shut the server down again.
+func newFakeDaemon(t *testing.T, handler http.Handler) (string, func()) {
+	dir, err := ioutil.TempDir("", "telemd-docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = listener
+	server.Start()
+
+	return socket, func() {
+		server.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func fakeDockerApi() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/containers/json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `[{"Id": "%s", "Names": ["/web"]}]`, testContainerId)
+	})
+	mux.HandleFunc("/containers/"+testContainerId+"/json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{
+			"Id": "%s",
+			"Name": "/web",
+			"Config": {"Image": "nginx:alpine", "Labels": {"app": "frontend"}},
+			"State": {"Pid": 4711, "Running": true}
+		}`, testContainerId)
+	})
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("filters") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"Type": "container", "Action": "start", "Actor": {"ID": "%s"}, "timeNano": 1600000000000000000}`, testContainerId)
+		_, _ = fmt.Fprintf(w, `{"Type": "container", "Action": "die", "Actor": {"ID": "%s"}, "timeNano": 1600000001000000000}`, testContainerId)
+	})
+
+	return mux
+}
+
+func TestClient_ContainerIds(t *testing.T) {
+	socket, closeDaemon := newFakeDaemon(t, fakeDockerApi())
+	defer closeDaemon()
+
+	ids, err := NewClient(socket).ContainerIds(context.Background())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if len(ids) != 1 || ids[0] != testContainerId {
+		t.Error("unexpected container ids", ids)
+	}
+}
+
+func TestClient_Inspect(t *testing.T) {
+	socket, closeDaemon := newFakeDaemon(t, fakeDockerApi())
+	defer closeDaemon()
+
+	container, err := NewClient(socket).Inspect(context.Background(), testContainerId)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if container.Name != "web" {
+		t.Error("expected name web, was", container.Name)
+	}
+	if container.Image != "nginx:alpine" {
+		t.Error("expected image nginx:alpine, was", container.Image)
+	}
+	if container.Labels["app"] != "frontend" {
+		t.Error("expected label app=frontend, was", container.Labels)
+	}
+	if container.Pid != 4711 {
+		t.Error("expected pid 4711, was", container.Pid)
+	}
+}
+
+func TestClient_InspectUnknownContainer(t *testing.T) {
+	socket, closeDaemon := newFakeDaemon(t, fakeDockerApi())
+	defer closeDaemon()
+
+	_, err := NewClient(socket).Inspect(context.Background(), "unknown")
+	if err == nil {
+		t.Error("expected an error for an unknown container")
+	}
+}
+
+func TestClient_Events(t *testing.T) {
+	socket, closeDaemon := newFakeDaemon(t, fakeDockerApi())
+	defer closeDaemon()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, errs := NewClient(socket).Events(ctx)
+
+	var actions []string
+	for event := range events {
+		if event.Id != testContainerId {
+			t.Error("unexpected container id", event.Id)
+		}
+		actions = append(actions, event.Action)
+	}
+
+	if len(actions) != 2 || actions[0] != "start" || actions[1] != "die" {
+		t.Error("unexpected actions", actions)
+	}
+
+	if err := <-errs; err == nil {
+		t.Error("expected the stream to end with an error once the server closed it")
+	}
+}
@@ -0,0 +1,45 @@
+package env
+
+import "time"
+
+type mapEnvironment struct {
+	values map[string]string
+}
+
+// NewMapEnvironment returns an environment of the given key-value pairs, e.g., read from a redis hash.
+func NewMapEnvironment(values map[string]string) Environment {
+	return &mapEnvironment{values: values}
+}
+
+func (env *mapEnvironment) Set(key string, value string) {
+	env.values[key] = value
+}
+
+func (env *mapEnvironment) Lookup(key string) (string, bool) {
+	value, ok := env.values[key]
+	return value, ok
+}
+
+func (env *mapEnvironment) Get(key string) string {
+	return env.values[key]
+}
+
+func (env *mapEnvironment) LookupInt(key string) (int64, bool, error) {
+	return LookupInt(env, key)
+}
+
+func (env *mapEnvironment) LookupFloat(key string) (float64, bool, error) {
+	return LookupFloat(env, key)
+}
+
+func (env *mapEnvironment) LookupFields(key string) ([]string, bool, error) {
+	return LookupFields(env, key)
+}
+
+func (env *mapEnvironment) LookupBool(key string) (bool, bool, error) {
+	return LookupBool(env, key)
+}
+
+func (env *mapEnvironment) LookupDuration(key string) (time.Duration, bool, error) {
+	return LookupDuration(env, key)
+}
@@ -0,0 +1,23 @@
+package env
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMapEnvironment_Lookup(t *testing.T) {
+	env := NewMapEnvironment(map[string]string{"telemd_period_cpu": "250ms"})
+
+	duration, ok, err := env.LookupDuration("telemd_period_cpu")
+	if err != nil || !ok {
+		t.Error("Expected environment to have 'telemd_period_cpu'", err)
+		t.FailNow()
+	}
+	if duration != 250*time.Millisecond {
+		t.Error("Expected value: 250ms, actual: ", duration)
+	}
+
+	if _, ok := env.Lookup("telemd_period_ram"); ok {
+		t.Error("Expected environment not to have 'telemd_period_ram'")
+	}
+}
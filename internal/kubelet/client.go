@@ -0,0 +1,153 @@
+package kubelet
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const DefaultUrl = "https://localhost:10250"
+const DefaultTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Client queries the pods running on the local node from the kubelet API.
+type Client struct {
+	url        string
+	tokenFile  string
+	httpClient *http.Client
+}
+
+type Pod struct {
+	Uid        string
+	Name       string
+	Namespace  string
+	Labels     map[string]string
+	OwnerKind  string
+	OwnerName  string
+	Containers []Container
+}
+
+type Container struct {
+	Name string
+	// Id is the container id without the runtime prefix (e.g., containerd://)
+	Id      string
+	Runtime string
+}
+
+type podListJson struct {
+	Items []struct {
+		Metadata struct {
+			Uid             string            `json:"uid"`
+			Name            string            `json:"name"`
+			Namespace       string            `json:"namespace"`
+			Labels          map[string]string `json:"labels"`
+			OwnerReferences []struct {
+				Kind       string `json:"kind"`
+				Name       string `json:"name"`
+				Controller bool   `json:"controller"`
+			} `json:"ownerReferences"`
+		} `json:"metadata"`
+		Status struct {
+			ContainerStatuses     []containerStatusJson `json:"containerStatuses"`
+			InitContainerStatuses []containerStatusJson `json:"initContainerStatuses"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type containerStatusJson struct {
+	Name        string `json:"name"`
+	ContainerID string `json:"containerID"`
+}
+
+// NewClient creates a new kubelet client for the given base url (e.g., https://localhost:10250). If tokenFile is not
+// empty, the file is read for every request and its content sent as bearer token. If insecure is true, the kubelet's
+// serving certificate is not verified.
+func NewClient(url string, tokenFile string, insecure bool) *Client {
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+	}
+
+	return &Client{
+		url:       strings.TrimSuffix(url, "/"),
+		tokenFile: tokenFile,
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   10 * time.Second,
+		},
+	}
+}
+
+// Pods returns the pods the kubelet currently manages.
+func (c *Client) Pods(ctx context.Context) ([]Pod, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/pods", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.tokenFile != "" {
+		token, err := ioutil.ReadFile(c.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kubelet api returned %s", response.Status)
+	}
+
+	var podList podListJson
+	if err := json.NewDecoder(response.Body).Decode(&podList); err != nil {
+		return nil, err
+	}
+
+	pods := make([]Pod, 0, len(podList.Items))
+	for _, item := range podList.Items {
+		pod := Pod{
+			Uid:       item.Metadata.Uid,
+			Name:      item.Metadata.Name,
+			Namespace: item.Metadata.Namespace,
+			Labels:    item.Metadata.Labels,
+		}
+
+		for _, owner := range item.Metadata.OwnerReferences {
+			if owner.Controller || pod.OwnerKind == "" {
+				pod.OwnerKind = owner.Kind
+				pod.OwnerName = owner.Name
+			}
+		}
+
+		statuses := append(item.Status.InitContainerStatuses, item.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			if status.ContainerID == "" {
+				continue // container has not been created yet
+			}
+			runtime, id := ParseContainerId(status.ContainerID)
+			pod.Containers = append(pod.Containers, Container{Name: status.Name, Id: id, Runtime: runtime})
+		}
+
+		pods = append(pods, pod)
+	}
+
+	return pods, nil
+}
+
+// ParseContainerId splits a container id as reported in a pod's container status (e.g.,
+// containerd://2cc54a6877a5...) into the runtime and the raw container id.
+func ParseContainerId(containerId string) (runtime string, id string) {
+	parts := strings.SplitN(containerId, "://", 2)
+	if len(parts) != 2 {
+		return "", containerId
+	}
+	return parts[0], parts[1]
+}
@@ -0,0 +1,117 @@
+package kubelet
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+const testPodList = `{
+  "kind": "PodList",
+  "items": [
+    {
+      "metadata": {
+        "name": "web-7d4b9c5f8-x2x7p",
+        "namespace": "shop",
+        "uid": "ae778fdf-394c-4356-9625-ea50666783b1",
+        "labels": {"app": "web"},
+        "ownerReferences": [
+          {"kind": "ReplicaSet", "name": "web-7d4b9c5f8", "controller": true}
+        ]
+      },
+      "status": {
+        "initContainerStatuses": [
+          {"name": "init", "containerID": "containerd://1111111111111111111111111111111111111111111111111111111111111111"}
+        ],
+        "containerStatuses": [
+          {"name": "nginx", "containerID": "containerd://2cc54a6877a50da0b6a2a5340dd1e8c5707a1d7d4b363e03b7cde76d2569f0c0"},
+          {"name": "pending"}
+        ]
+      }
+    }
+  ]
+}`
+
+func newKubeletStub(t *testing.T, token string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pods" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(testPodList))
+	}))
+}
+
+func TestClient_Pods(t *testing.T) {
+	server := newKubeletStub(t, "")
+	defer server.Close()
+
+	pods, err := NewClient(server.URL, "", false).Pods(context.Background())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if len(pods) != 1 {
+		t.Fatal("expected one pod, got", len(pods))
+	}
+
+	pod := pods[0]
+	if pod.Namespace != "shop" || pod.Name != "web-7d4b9c5f8-x2x7p" {
+		t.Error("unexpected pod", pod.Namespace, pod.Name)
+	}
+	if pod.OwnerKind != "ReplicaSet" || pod.OwnerName != "web-7d4b9c5f8" {
+		t.Error("unexpected owner", pod.OwnerKind, pod.OwnerName)
+	}
+	if pod.Labels["app"] != "web" {
+		t.Error("unexpected labels", pod.Labels)
+	}
+	if len(pod.Containers) != 2 {
+		t.Fatal("expected containers without id to be skipped, got", pod.Containers)
+	}
+	if pod.Containers[1].Name != "nginx" || pod.Containers[1].Runtime != "containerd" {
+		t.Error("unexpected container", pod.Containers[1])
+	}
+	if pod.Containers[1].Id != "2cc54a6877a50da0b6a2a5340dd1e8c5707a1d7d4b363e03b7cde76d2569f0c0" {
+		t.Error("unexpected container id", pod.Containers[1].Id)
+	}
+}
+
+func TestClient_PodsWithToken(t *testing.T) {
+	server := newKubeletStub(t, "secret")
+	defer server.Close()
+
+	tokenFile, err := ioutil.TempFile("", "telemd-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tokenFile.Name())
+	_, _ = tokenFile.WriteString("secret\n")
+	_ = tokenFile.Close()
+
+	if _, err := NewClient(server.URL, "", false).Pods(context.Background()); err == nil {
+		t.Error("expected request without token to fail")
+	}
+
+	if _, err := NewClient(server.URL, tokenFile.Name(), false).Pods(context.Background()); err != nil {
+		t.Error("unexpected error", err)
+	}
+}
+
+func TestParseContainerId(t *testing.T) {
+	runtime, id := ParseContainerId("docker://dc65d1e56729")
+	if runtime != "docker" || id != "dc65d1e56729" {
+		t.Error("unexpected result", runtime, id)
+	}
+
+	runtime, id = ParseContainerId("dc65d1e56729")
+	if runtime != "" || id != "dc65d1e56729" {
+		t.Error("unexpected result", runtime, id)
+	}
+}
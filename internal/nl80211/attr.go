@@ -0,0 +1,117 @@
+package nl80211
+
+import (
+	"encoding/binary"
+	"errors"
+	"unsafe"
+)
+
+const (
+	nlaHeaderLen   = 4
+	nlaTypeMask    = ^uint16(0xc000) // strips NLA_F_NESTED and NLA_F_NET_BYTEORDER
+	genlHeaderLen  = 4
+	nlmsgHeaderLen = 16
+)
+
+var errShortAttribute = errors.New("netlink attribute exceeds message")
+
+// nativeEndian is the byte order of the host, which netlink uses for its headers and attribute values.
+var nativeEndian binary.ByteOrder
+
+func init() {
+	i := uint16(1)
+	if *(*byte)(unsafe.Pointer(&i)) == 1 {
+		nativeEndian = binary.LittleEndian
+	} else {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+// attribute is a netlink attribute (TLV).
+type attribute struct {
+	Type uint16
+	Data []byte
+}
+
+// parseAttributes splits the given buffer into netlink attributes.
+func parseAttributes(b []byte) ([]attribute, error) {
+	var attrs []attribute
+
+	for len(b) >= nlaHeaderLen {
+		length := int(nativeEndian.Uint16(b[0:2]))
+		if length < nlaHeaderLen || length > len(b) {
+			return attrs, errShortAttribute
+		}
+
+		attrs = append(attrs, attribute{
+			Type: nativeEndian.Uint16(b[2:4]) & nlaTypeMask,
+			Data: b[nlaHeaderLen:length],
+		})
+
+		b = b[align(length):]
+	}
+
+	return attrs, nil
+}
+
+// encodeAttributes serializes the given attributes, padding each attribute to four bytes.
+func encodeAttributes(attrs []attribute) []byte {
+	var b []byte
+
+	for _, attr := range attrs {
+		length := nlaHeaderLen + len(attr.Data)
+		buf := make([]byte, align(length))
+		nativeEndian.PutUint16(buf[0:2], uint16(length))
+		nativeEndian.PutUint16(buf[2:4], attr.Type)
+		copy(buf[nlaHeaderLen:], attr.Data)
+		b = append(b, buf...)
+	}
+
+	return b
+}
+
+func align(length int) int {
+	return (length + 3) &^ 3
+}
+
+func uint32Attr(t uint16, v uint32) attribute {
+	b := make([]byte, 4)
+	nativeEndian.PutUint32(b, v)
+	return attribute{Type: t, Data: b}
+}
+
+func stringAttr(t uint16, v string) attribute {
+	return attribute{Type: t, Data: append([]byte(v), 0)}
+}
+
+func (a attribute) uint8() uint8 {
+	if len(a.Data) < 1 {
+		return 0
+	}
+	return a.Data[0]
+}
+
+func (a attribute) uint16() uint16 {
+	if len(a.Data) < 2 {
+		return 0
+	}
+	return nativeEndian.Uint16(a.Data)
+}
+
+func (a attribute) uint32() uint32 {
+	if len(a.Data) < 4 {
+		return 0
+	}
+	return nativeEndian.Uint32(a.Data)
+}
+
+func (a attribute) string() string {
+	data := a.Data
+	for i, c := range data {
+		if c == 0 {
+			data = data[:i]
+			break
+		}
+	}
+	return string(data)
+}
@@ -0,0 +1,185 @@
+//go:build linux
+// +build linux
+
+package nl80211
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const receiveTimeout = 5 * time.Second
+
+// Client is a generic netlink connection to the nl80211 family. It is safe for concurrent use.
+type Client struct {
+	mutex    sync.Mutex
+	fd       int
+	familyId uint16
+	seq      uint32
+}
+
+// New opens a generic netlink socket and resolves the nl80211 family. It fails if the kernel has no wireless
+// (cfg80211) support.
+func New() (*Client, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_GENERIC)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		_ = syscall.Close(fd)
+		return nil, err
+	}
+
+	timeout := syscall.NsecToTimeval(receiveTimeout.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &timeout); err != nil {
+		_ = syscall.Close(fd)
+		return nil, err
+	}
+
+	client := &Client{fd: fd}
+
+	msgs, err := client.execute(genlIdCtrl, ctrlCmdGetFamily, 0, []attribute{stringAttr(ctrlAttrFamilyName, nl80211FamilyName)})
+	if err != nil {
+		_ = syscall.Close(fd)
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		_ = syscall.Close(fd)
+		return nil, fmt.Errorf("generic netlink family %s not found", nl80211FamilyName)
+	}
+
+	client.familyId, err = parseFamilyId(msgs[0])
+	if err != nil {
+		_ = syscall.Close(fd)
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func (c *Client) Close() error {
+	return syscall.Close(c.fd)
+}
+
+// Interfaces returns all wireless interfaces.
+func (c *Client) Interfaces() ([]Interface, error) {
+	msgs, err := c.execute(c.familyId, cmdGetInterface, syscall.NLM_F_DUMP, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	interfaces := make([]Interface, 0, len(msgs))
+	for _, attrs := range msgs {
+		interfaces = append(interfaces, parseInterface(attrs))
+	}
+	return interfaces, nil
+}
+
+// StationInfo returns the stations the given interface is connected to. For an interface in managed mode, this is
+// at most the access point it is associated with.
+func (c *Client) StationInfo(ifindex int) ([]StationInfo, error) {
+	msgs, err := c.execute(c.familyId, cmdGetStation, syscall.NLM_F_DUMP, []attribute{uint32Attr(attrIfindex, uint32(ifindex))})
+	if err != nil {
+		return nil, err
+	}
+
+	stations := make([]StationInfo, 0, len(msgs))
+	for _, attrs := range msgs {
+		station, err := parseStationInfo(attrs)
+		if err != nil {
+			return nil, err
+		}
+		stations = append(stations, station)
+	}
+	return stations, nil
+}
+
+// Surveys returns the channel survey data of the given interface.
+func (c *Client) Surveys(ifindex int) ([]Survey, error) {
+	msgs, err := c.execute(c.familyId, cmdGetSurvey, syscall.NLM_F_DUMP, []attribute{uint32Attr(attrIfindex, uint32(ifindex))})
+	if err != nil {
+		return nil, err
+	}
+
+	surveys := make([]Survey, 0, len(msgs))
+	for _, attrs := range msgs {
+		survey, err := parseSurvey(attrs)
+		if err != nil {
+			return nil, err
+		}
+		surveys = append(surveys, survey)
+	}
+	return surveys, nil
+}
+
+// execute sends a generic netlink request and returns the attributes of all response messages.
+func (c *Client) execute(family uint16, cmd uint8, flags uint16, attrs []attribute) ([][]attribute, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.seq++
+	seq := c.seq
+
+	payload := encodeAttributes(attrs)
+	msg := make([]byte, nlmsgHeaderLen+genlHeaderLen, nlmsgHeaderLen+genlHeaderLen+len(payload))
+	nativeEndian.PutUint16(msg[4:6], family)
+	nativeEndian.PutUint16(msg[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_ACK|flags)
+	nativeEndian.PutUint32(msg[8:12], seq)
+	msg[16] = cmd
+	msg[17] = nl80211GenlVersion
+	msg = append(msg, payload...)
+	nativeEndian.PutUint32(msg[0:4], uint32(len(msg)))
+
+	if err := syscall.Sendto(c.fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, err
+	}
+
+	var results [][]attribute
+	buf := make([]byte, syscall.Getpagesize()*4)
+
+	for {
+		n, _, err := syscall.Recvfrom(c.fd, buf, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range msgs {
+			if m.Header.Seq != seq {
+				continue // late reply to an earlier request
+			}
+
+			switch m.Header.Type {
+			case syscall.NLMSG_DONE:
+				return results, nil
+			case syscall.NLMSG_ERROR:
+				if len(m.Data) < 4 {
+					return nil, fmt.Errorf("malformed netlink error message")
+				}
+				if errno := int32(nativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+					return nil, syscall.Errno(-errno)
+				}
+				// an ACK terminates non-dump requests
+				if flags&syscall.NLM_F_DUMP == 0 {
+					return results, nil
+				}
+			default:
+				if len(m.Data) < genlHeaderLen {
+					continue
+				}
+				attrs, err := parseAttributes(m.Data[genlHeaderLen:])
+				if err != nil {
+					return nil, err
+				}
+				results = append(results, attrs)
+			}
+		}
+	}
+}
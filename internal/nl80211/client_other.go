@@ -0,0 +1,27 @@
+//go:build !linux
+// +build !linux
+
+package nl80211
+
+// Client is not supported on this platform.
+type Client struct{}
+
+func New() (*Client, error) {
+	return nil, ErrNotSupported
+}
+
+func (c *Client) Close() error {
+	return ErrNotSupported
+}
+
+func (c *Client) Interfaces() ([]Interface, error) {
+	return nil, ErrNotSupported
+}
+
+func (c *Client) StationInfo(ifindex int) ([]StationInfo, error) {
+	return nil, ErrNotSupported
+}
+
+func (c *Client) Surveys(ifindex int) ([]Survey, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,230 @@
+// Package nl80211 queries wireless interface and station information from the kernel via the nl80211 generic
+// netlink family, which is what tools like iw use under the hood.
+package nl80211
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// generic netlink controller
+const (
+	genlIdCtrl          = 0x10
+	ctrlCmdGetFamily    = 3
+	ctrlAttrFamilyId    = 1
+	ctrlAttrFamilyName  = 2
+	nl80211FamilyName   = "nl80211"
+	nl80211GenlVersion  = 1
+	ifTypeStation       = 2
+	rateInfoBitrate     = 1
+	rateInfoBitrate32   = 5
+	surveyInfoFrequency = 1
+	surveyInfoNoise     = 2
+	surveyInfoInUse     = 3
+)
+
+// nl80211 commands
+const (
+	cmdGetInterface = 5
+	cmdGetStation   = 17
+	cmdGetSurvey    = 50
+)
+
+// nl80211 attributes
+const (
+	attrIfindex    = 3
+	attrIfname     = 4
+	attrIftype     = 5
+	attrMac        = 6
+	attrStaInfo    = 21
+	attrWiphyFreq  = 38
+	attrSsid       = 52
+	attrSurveyInfo = 84
+)
+
+// nl80211 station info attributes
+const (
+	staInfoRxBytes       = 2
+	staInfoTxBytes       = 3
+	staInfoSignal        = 7
+	staInfoTxBitrate     = 8
+	staInfoTxRetries     = 11
+	staInfoTxFailed      = 12
+	staInfoRxBitrate     = 14
+	staInfoConnectedTime = 16
+)
+
+// ErrNotSupported is returned on platforms without nl80211.
+var ErrNotSupported = errors.New("nl80211 is not supported on this platform")
+
+// Interface is a wireless network interface.
+type Interface struct {
+	Index int
+	Name  string
+	// Station is true if the interface is a client (managed mode) interface
+	Station bool
+	// Frequency is the frequency of the interface's current channel in MHz, or 0 if unknown
+	Frequency int
+	SSID      string
+}
+
+// StationInfo contains information about a station an interface is connected to. For an interface in managed mode,
+// this is the access point.
+type StationInfo struct {
+	// BSSID is the MAC address of the station
+	BSSID net.HardwareAddr
+	// Signal is the signal strength in dBm
+	Signal int
+	// TxBitrate and RxBitrate are the last used bitrates in Mbit/s
+	TxBitrate     float64
+	RxBitrate     float64
+	TxRetries     uint32
+	TxFailed      uint32
+	RxBytes       uint32
+	TxBytes       uint32
+	ConnectedTime time.Duration
+}
+
+// Survey contains the channel survey data of an interface.
+type Survey struct {
+	Frequency int
+	// Noise is the noise level in dBm
+	Noise    int
+	HasNoise bool
+	InUse    bool
+}
+
+func parseInterface(attrs []attribute) Interface {
+	var iface Interface
+
+	for _, attr := range attrs {
+		switch attr.Type {
+		case attrIfindex:
+			iface.Index = int(attr.uint32())
+		case attrIfname:
+			iface.Name = attr.string()
+		case attrIftype:
+			iface.Station = attr.uint32() == ifTypeStation
+		case attrWiphyFreq:
+			iface.Frequency = int(attr.uint32())
+		case attrSsid:
+			iface.SSID = string(attr.Data)
+		}
+	}
+
+	return iface
+}
+
+func parseStationInfo(attrs []attribute) (StationInfo, error) {
+	var info StationInfo
+
+	for _, attr := range attrs {
+		switch attr.Type {
+		case attrMac:
+			info.BSSID = net.HardwareAddr(append([]byte(nil), attr.Data...))
+		case attrStaInfo:
+			nested, err := parseAttributes(attr.Data)
+			if err != nil {
+				return info, err
+			}
+			if err := info.parseNested(nested); err != nil {
+				return info, err
+			}
+		}
+	}
+
+	return info, nil
+}
+
+func (info *StationInfo) parseNested(attrs []attribute) error {
+	for _, attr := range attrs {
+		switch attr.Type {
+		case staInfoRxBytes:
+			info.RxBytes = attr.uint32()
+		case staInfoTxBytes:
+			info.TxBytes = attr.uint32()
+		case staInfoSignal:
+			info.Signal = int(int8(attr.uint8()))
+		case staInfoTxRetries:
+			info.TxRetries = attr.uint32()
+		case staInfoTxFailed:
+			info.TxFailed = attr.uint32()
+		case staInfoConnectedTime:
+			info.ConnectedTime = time.Duration(attr.uint32()) * time.Second
+		case staInfoTxBitrate, staInfoRxBitrate:
+			rate, err := parseRateInfo(attr.Data)
+			if err != nil {
+				return err
+			}
+			if attr.Type == staInfoTxBitrate {
+				info.TxBitrate = rate
+			} else {
+				info.RxBitrate = rate
+			}
+		}
+	}
+	return nil
+}
+
+// parseRateInfo returns the bitrate in Mbit/s from a nested rate info attribute.
+func parseRateInfo(b []byte) (float64, error) {
+	attrs, err := parseAttributes(b)
+	if err != nil {
+		return 0, err
+	}
+
+	var rate uint32
+	for _, attr := range attrs {
+		switch attr.Type {
+		case rateInfoBitrate32:
+			rate = attr.uint32()
+		case rateInfoBitrate:
+			if rate == 0 {
+				rate = uint32(attr.uint16())
+			}
+		}
+	}
+
+	// the kernel reports the bitrate in units of 100 kbit/s
+	return float64(rate) / 10, nil
+}
+
+func parseSurvey(attrs []attribute) (Survey, error) {
+	var survey Survey
+
+	for _, attr := range attrs {
+		if attr.Type != attrSurveyInfo {
+			continue
+		}
+
+		nested, err := parseAttributes(attr.Data)
+		if err != nil {
+			return survey, err
+		}
+
+		for _, info := range nested {
+			switch info.Type {
+			case surveyInfoFrequency:
+				survey.Frequency = int(info.uint32())
+			case surveyInfoNoise:
+				survey.Noise = int(int8(info.uint8()))
+				survey.HasNoise = true
+			case surveyInfoInUse:
+				survey.InUse = true
+			}
+		}
+	}
+
+	return survey, nil
+}
+
+func parseFamilyId(attrs []attribute) (uint16, error) {
+	for _, attr := range attrs {
+		if attr.Type == ctrlAttrFamilyId {
+			return attr.uint16(), nil
+		}
+	}
+	return 0, fmt.Errorf("generic netlink family %s not found", nl80211FamilyName)
+}
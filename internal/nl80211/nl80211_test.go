@@ -0,0 +1,147 @@
+package nl80211
+
+import (
+	"testing"
+	"time"
+)
+
+func uint8Attr(t uint16, v uint8) attribute {
+	return attribute{Type: t, Data: []byte{v}}
+}
+
+func uint16Attr(t uint16, v uint16) attribute {
+	b := make([]byte, 2)
+	nativeEndian.PutUint16(b, v)
+	return attribute{Type: t, Data: b}
+}
+
+func nestedAttr(t uint16, attrs ...attribute) attribute {
+	// the kernel sets NLA_F_NESTED on nested attributes, which must be ignored when parsing
+	return attribute{Type: t | 0x8000, Data: encodeAttributes(attrs)}
+}
+
+// message encodes and parses the given attributes, as they would be received from the kernel.
+func message(t *testing.T, attrs ...attribute) []attribute {
+	parsed, err := parseAttributes(encodeAttributes(attrs))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	return parsed
+}
+
+func TestParseAttributes_RoundTrip(t *testing.T) {
+	b := encodeAttributes([]attribute{
+		stringAttr(attrIfname, "wlan0"),
+		uint32Attr(attrIfindex, 3),
+		uint8Attr(staInfoSignal, 0xc4),
+	})
+
+	if len(b)%4 != 0 {
+		t.Error("expected attributes to be aligned, length was", len(b))
+	}
+
+	attrs, err := parseAttributes(b)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(attrs) != 3 {
+		t.Fatal("expected 3 attributes, got", len(attrs))
+	}
+	if attrs[0].string() != "wlan0" {
+		t.Error("unexpected string value", attrs[0].string())
+	}
+	if attrs[1].uint32() != 3 {
+		t.Error("unexpected uint32 value", attrs[1].uint32())
+	}
+}
+
+func TestParseAttributes_Truncated(t *testing.T) {
+	b := encodeAttributes([]attribute{stringAttr(attrIfname, "wlan0")})
+
+	if _, err := parseAttributes(b[:6]); err == nil {
+		t.Error("expected an error for a truncated attribute")
+	}
+}
+
+func TestParseInterface(t *testing.T) {
+	iface := parseInterface([]attribute{
+		uint32Attr(attrIfindex, 3),
+		stringAttr(attrIfname, "wlan0"),
+		uint32Attr(attrIftype, ifTypeStation),
+		uint32Attr(attrWiphyFreq, 5180),
+		{Type: attrSsid, Data: []byte("edgerun")},
+	})
+
+	if iface.Index != 3 || iface.Name != "wlan0" || !iface.Station {
+		t.Error("unexpected interface", iface)
+	}
+	if iface.Frequency != 5180 {
+		t.Error("expected frequency 5180, was", iface.Frequency)
+	}
+	if iface.SSID != "edgerun" {
+		t.Error("expected ssid edgerun, was", iface.SSID)
+	}
+}
+
+func TestParseStationInfo(t *testing.T) {
+	station, err := parseStationInfo(message(t,
+		uint32Attr(attrIfindex, 3),
+		attribute{Type: attrMac, Data: []byte{0x02, 0x42, 0xac, 0x11, 0x00, 0x02}},
+		nestedAttr(attrStaInfo,
+			uint8Attr(staInfoSignal, 0xc4), // -60 dBm
+			uint32Attr(staInfoTxRetries, 12),
+			uint32Attr(staInfoTxFailed, 2),
+			uint32Attr(staInfoConnectedTime, 3600),
+			nestedAttr(staInfoTxBitrate,
+				uint16Attr(rateInfoBitrate, 1),
+				uint32Attr(rateInfoBitrate32, 8667), // 866.7 Mbit/s
+			),
+			nestedAttr(staInfoRxBitrate,
+				uint16Attr(rateInfoBitrate, 540), // 54 Mbit/s
+			),
+		),
+	))
+
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if station.BSSID.String() != "02:42:ac:11:00:02" {
+		t.Error("unexpected bssid", station.BSSID)
+	}
+	if station.Signal != -60 {
+		t.Error("expected signal -60, was", station.Signal)
+	}
+	if station.TxBitrate != 866.7 {
+		t.Error("expected tx bitrate 866.7, was", station.TxBitrate)
+	}
+	if station.RxBitrate != 54 {
+		t.Error("expected rx bitrate 54, was", station.RxBitrate)
+	}
+	if station.TxRetries != 12 || station.TxFailed != 2 {
+		t.Error("unexpected tx retries/failed", station.TxRetries, station.TxFailed)
+	}
+	if station.ConnectedTime != time.Hour {
+		t.Error("expected connected time 1h, was", station.ConnectedTime)
+	}
+}
+
+func TestParseSurvey(t *testing.T) {
+	survey, err := parseSurvey(message(t,
+		uint32Attr(attrIfindex, 3),
+		nestedAttr(attrSurveyInfo,
+			uint32Attr(surveyInfoFrequency, 2412),
+			uint8Attr(surveyInfoNoise, 0xa1), // -95 dBm
+			attribute{Type: surveyInfoInUse},
+		),
+	))
+
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if survey.Frequency != 2412 || !survey.InUse {
+		t.Error("unexpected survey", survey)
+	}
+	if !survey.HasNoise || survey.Noise != -95 {
+		t.Error("expected noise -95, was", survey.Noise)
+	}
+}
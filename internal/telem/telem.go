@@ -28,6 +28,26 @@ type telemetryChannel struct {
 	C chan Telemetry
 }
 
+// Event is a discrete occurrence on a node (e.g., a container was started), as opposed to a sampled value. Events are
+// reported into the topic telem/<node>/events/<kind>.
+type Event struct {
+	Node       string
+	Kind       string
+	Type       string
+	Time       time.Time
+	Attributes map[string]string
+}
+
+type EventChannel interface {
+	Channel() chan Event
+	Put(event Event)
+	Close()
+}
+
+type eventChannel struct {
+	C chan Event
+}
+
 func NewTelemetry(topic string, value float64) Telemetry {
 	return NewNodeTelemetry(NodeName, topic, value)
 }
@@ -41,6 +61,16 @@ func NewNodeTelemetry(nodeName string, topic string, value float64) Telemetry {
 	}
 }
 
+func NewEvent(kind string, eventType string, attributes map[string]string) Event {
+	return Event{
+		Node:       NodeName,
+		Kind:       kind,
+		Type:       eventType,
+		Time:       time.Now(),
+		Attributes: attributes,
+	}
+}
+
 func NewTelemetryChannel() TelemetryChannel {
 	c := make(chan Telemetry)
 	return &telemetryChannel{
@@ -67,3 +97,26 @@ func (t *telemetryChannel) Put(telemetry Telemetry) {
 func (t *telemetryChannel) Close() {
 	close(t.C)
 }
+
+func NewEventChannel() EventChannel {
+	return &eventChannel{
+		C: make(chan Event),
+	}
+}
+
+// Topic returns the topic of the event relative to the node, i.e., events/<kind>.
+func (e Event) Topic() string {
+	return "events" + TopicSeparator + e.Kind
+}
+
+func (e *eventChannel) Channel() chan Event {
+	return e.C
+}
+
+func (e *eventChannel) Put(event Event) {
+	e.C <- event
+}
+
+func (e *eventChannel) Close() {
+	close(e.C)
+}
@@ -0,0 +1,163 @@
+package telemd
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandEnvelope is a signed command. The signature is computed over "<channel>\n<time>\n<nonce>\n<payload>", where
+// channel is the topic the command is published to, time is the UNIX timestamp in seconds, and payload is the
+// plain-string or JSON command.
+type CommandEnvelope struct {
+	Channel   string `json:"channel"`
+	Payload   string `json:"payload"`
+	Time      int64  `json:"time"`
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+}
+
+// signedData returns the bytes the signature of the envelope is computed over.
+func (envelope CommandEnvelope) signedData() []byte {
+	return []byte(envelope.Channel + "\n" + strconv.FormatInt(envelope.Time, 10) + "\n" + envelope.Nonce + "\n" +
+		envelope.Payload)
+}
+
+type commandKey interface {
+	verify(data []byte, signature []byte) bool
+}
+
+type hmacKey []byte
+
+func (key hmacKey) verify(data []byte, signature []byte) bool {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hmac.Equal(mac.Sum(nil), signature)
+}
+
+type ed25519Key ed25519.PublicKey
+
+func (key ed25519Key) verify(data []byte, signature []byte) bool {
+	return ed25519.Verify(ed25519.PublicKey(key), data, signature)
+}
+
+// parseCommandKey parses a key in the format hmac:<base64 secret> or ed25519:<base64 public key>.
+func parseCommandKey(s string) (commandKey, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("expected hmac:<secret> or ed25519:<public key>")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s key: %v", parts[0], err)
+	}
+
+	switch parts[0] {
+	case "hmac":
+		if len(data) < 16 {
+			return nil, errors.New("hmac key must have at least 16 bytes")
+		}
+		return hmacKey(data), nil
+	case "ed25519":
+		if len(data) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("ed25519 public key must have %d bytes", ed25519.PublicKeySize)
+		}
+		return ed25519Key(data), nil
+	default:
+		return nil, errors.New("unknown key type " + parts[0])
+	}
+}
+
+// CommandAuthenticator verifies signed commands. A command is accepted if it is signed by any of the keys, its
+// timestamp is within the allowed clock skew, and its nonce was not used before.
+type CommandAuthenticator struct {
+	keys    []commandKey
+	maxSkew time.Duration
+
+	mutex  sync.Mutex
+	nonces map[string]time.Time
+}
+
+// NewCommandAuthenticator creates an authenticator for the given keys, see parseCommandKey for their format.
+func NewCommandAuthenticator(keys []string, maxSkew time.Duration) (*CommandAuthenticator, error) {
+	auth := &CommandAuthenticator{
+		maxSkew: maxSkew,
+		nonces:  make(map[string]time.Time),
+	}
+
+	for _, s := range keys {
+		key, err := parseCommandKey(s)
+		if err != nil {
+			return nil, err
+		}
+		auth.keys = append(auth.keys, key)
+	}
+
+	if len(auth.keys) == 0 {
+		return nil, errors.New("no keys given")
+	}
+	return auth, nil
+}
+
+// Verify checks the signed command envelope that was received on the given channel, and returns the command it
+// contains. A command is only accepted on the channel it was signed for, so that it cannot be replayed to other nodes
+// or groups.
+func (auth *CommandAuthenticator) Verify(channel string, message string, now time.Time) (string, error) {
+	var envelope CommandEnvelope
+	if err := json.Unmarshal([]byte(message), &envelope); err != nil {
+		return "", errors.New("command is not signed")
+	}
+	if envelope.Nonce == "" || envelope.Signature == "" {
+		return "", errors.New("command is not signed")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(envelope.Signature)
+	if err != nil {
+		return "", errors.New("invalid signature encoding")
+	}
+
+	verified := false
+	data := envelope.signedData()
+	for _, key := range auth.keys {
+		if key.verify(data, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return "", errors.New("invalid signature")
+	}
+	if envelope.Channel != channel {
+		return "", fmt.Errorf("command was signed for channel %s", envelope.Channel)
+	}
+
+	skew := now.Sub(time.Unix(envelope.Time, 0))
+	if skew > auth.maxSkew || skew < -auth.maxSkew {
+		return "", fmt.Errorf("command timestamp is off by %v", skew)
+	}
+
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+
+	// a nonce only needs to be remembered as long as its timestamp would be accepted
+	for nonce, seen := range auth.nonces {
+		if now.Sub(seen) > 2*auth.maxSkew {
+			delete(auth.nonces, nonce)
+		}
+	}
+	if _, ok := auth.nonces[envelope.Nonce]; ok {
+		return "", errors.New("command was replayed")
+	}
+	auth.nonces[envelope.Nonce] = now
+
+	return envelope.Payload, nil
+}
@@ -0,0 +1,113 @@
+package telemd
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+var testHmacSecret = []byte("0123456789abcdef0123456789abcdef")
+
+const testCommandChannel = "telemcmd/pi-01"
+
+func signHmac(channel string, payload string, t time.Time, nonce string) string {
+	envelope := CommandEnvelope{Channel: channel, Payload: payload, Time: t.Unix(), Nonce: nonce}
+	mac := hmac.New(sha256.New, testHmacSecret)
+	mac.Write(envelope.signedData())
+	envelope.Signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	data, _ := json.Marshal(envelope)
+	return string(data)
+}
+
+func TestCommandAuthenticator_Hmac(t *testing.T) {
+	auth, err := NewCommandAuthenticator([]string{"hmac:" + base64.StdEncoding.EncodeToString(testHmacSecret)}, 30*time.Second)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	now := time.Now()
+
+	payload, err := auth.Verify(testCommandChannel, signHmac(testCommandChannel, "pause", now, "n1"), now)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if payload != "pause" {
+		t.Error("Unexpected payload", payload)
+	}
+
+	if _, err := auth.Verify(testCommandChannel, signHmac(testCommandChannel, "pause", now, "n1"), now); err == nil {
+		t.Error("Expected a replayed command to be rejected")
+	}
+	old := signHmac(testCommandChannel, "pause", now.Add(-time.Minute), "n2")
+	if _, err := auth.Verify(testCommandChannel, old, now); err == nil {
+		t.Error("Expected an old command to be rejected")
+	}
+	if _, err := auth.Verify(testCommandChannel, "pause", now); err == nil {
+		t.Error("Expected an unsigned command to be rejected")
+	}
+
+	// a command signed for another node, or for a group, is rejected on this channel
+	if _, err := auth.Verify(testCommandChannel, signHmac("telemcmd/pi-02", "pause", now, "n4"), now); err == nil {
+		t.Error("Expected a command signed for another channel to be rejected")
+	}
+
+	tampered := CommandEnvelope{}
+	_ = json.Unmarshal([]byte(signHmac(testCommandChannel, "pause", now, "n3")), &tampered)
+	tampered.Payload = "disable cpu"
+	data, _ := json.Marshal(tampered)
+	if _, err := auth.Verify(testCommandChannel, string(data), now); err == nil {
+		t.Error("Expected a tampered command to be rejected")
+	}
+}
+
+func TestCommandAuthenticator_Ed25519(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublic, _, _ := ed25519.GenerateKey(nil)
+
+	keys := []string{
+		"ed25519:" + base64.StdEncoding.EncodeToString(otherPublic),
+		"ed25519:" + base64.StdEncoding.EncodeToString(public),
+	}
+	auth, err := NewCommandAuthenticator(keys, 30*time.Second)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	now := time.Now()
+	envelope := CommandEnvelope{
+		Channel: testCommandChannel,
+		Payload: `{"id": "1", "name": "snapshot"}`,
+		Time:    now.Unix(),
+		Nonce:   "n1",
+	}
+	envelope.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(private, envelope.signedData()))
+	data, _ := json.Marshal(envelope)
+
+	payload, err := auth.Verify(testCommandChannel, string(data), now)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if payload != envelope.Payload {
+		t.Error("Unexpected payload", payload)
+	}
+
+	// an hmac-signed command is rejected if only ed25519 keys are configured
+	if _, err := auth.Verify(testCommandChannel, signHmac(testCommandChannel, "pause", now, "n2"), now); err == nil {
+		t.Error("Expected a command signed with an unknown key to be rejected")
+	}
+}
+
+func TestNewCommandAuthenticator_InvalidKeys(t *testing.T) {
+	for _, key := range []string{"secret", "hmac:not base64!", "hmac:c2hvcnQ=", "ed25519:c2hvcnQ=", "rsa:c2hvcnQ="} {
+		if _, err := NewCommandAuthenticator([]string{key}, time.Second); err == nil {
+			t.Error("Expected an error for key", key)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package telemd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// minCommandPeriod is the shortest period that can be set by command, so that a command cannot overload the node
+	minCommandPeriod = 10 * time.Millisecond
+	// maxBurstDuration is the longest duration of a burst
+	maxBurstDuration = time.Hour
+)
+
+// checkCommandPeriod returns an error if the period cannot be set by command.
+func checkCommandPeriod(period time.Duration) error {
+	if period < minCommandPeriod {
+		return fmt.Errorf("period must be at least %v", minCommandPeriod)
+	}
+	return nil
+}
+
+// burst temporarily samples instruments with a shorter period.
+type burst struct {
+	instruments []string
+	period      time.Duration
+	timer       *time.Timer
+}
+
+// Burst switches the given instruments (all enabled instruments if none are given) to the given period for the given
+// duration, after which their previous period is restored. While bursts overlap, an instrument runs with the shortest
+// period of the active bursts that include it, and a burst never makes an instrument run slower than its own period.
+func (daemon *Daemon) Burst(instruments []string, period time.Duration, duration time.Duration) error {
+	if err := checkCommandPeriod(period); err != nil {
+		return err
+	}
+	if duration <= 0 || duration > maxBurstDuration {
+		return fmt.Errorf("duration must be positive and at most %v", maxBurstDuration)
+	}
+
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+
+	if !daemon.running {
+		return errors.New("daemon is not running")
+	}
+
+	if len(instruments) == 0 {
+		for name := range daemon.tickers {
+			instruments = append(instruments, name)
+		}
+	}
+	for _, name := range instruments {
+		if _, ok := daemon.tickers[name]; !ok {
+			return errors.New("instrument " + name + " is not enabled")
+		}
+	}
+
+	id := daemon.nextBurst
+	daemon.nextBurst++
+
+	b := &burst{instruments: instruments, period: period}
+	b.timer = time.AfterFunc(duration, func() {
+		daemon.endBurst(id)
+	})
+	daemon.bursts[id] = b
+
+	log.Println("sampling", instruments, "every", period, "for", duration)
+	for _, name := range instruments {
+		daemon.applyPeriod(name)
+	}
+	return nil
+}
+
+func (daemon *Daemon) endBurst(id int) {
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+
+	b, ok := daemon.bursts[id]
+	if !ok {
+		return
+	}
+	delete(daemon.bursts, id)
+
+	log.Println("burst of", b.instruments, "ended")
+	for _, name := range b.instruments {
+		daemon.applyPeriod(name)
+	}
+}
+
+// stopBursts cancels all active bursts without restoring the periods.
+func (daemon *Daemon) stopBursts() {
+	for id, b := range daemon.bursts {
+		b.timer.Stop()
+		delete(daemon.bursts, id)
+	}
+}
+
+// period returns the period of the instrument without bursts, i.e., the one set by command or the configured one.
+func (daemon *Daemon) period(name string) time.Duration {
+	if period, ok := daemon.periods[name]; ok {
+		return period
+	}
+	if period, ok := daemon.cfg.Instruments.Periods[name]; ok {
+		return period
+	}
+	return 1 * time.Second
+}
+
+// applyPeriod sets the period of the instrument's ticker, taking active bursts into account. The daemon's mutex must
+// be held.
+func (daemon *Daemon) applyPeriod(name string) {
+	ticker, ok := daemon.tickers[name]
+	if !ok || !daemon.running {
+		return
+	}
+
+	period := daemon.period(name)
+	for _, b := range daemon.bursts {
+		for _, instrument := range b.instruments {
+			if instrument == name && b.period < period {
+				period = b.period
+			}
+		}
+	}
+
+	ticker.SetPeriod(period)
+}
@@ -0,0 +1,108 @@
+package telemd
+
+import (
+	"testing"
+	"time"
+)
+
+func hasPeriod(daemon *Daemon, name string, period time.Duration) bool {
+	return waitForStats(daemon, name, func(stats InstrumentStats) bool { return stats.Period == period })
+}
+
+func TestDaemon_Burst(t *testing.T) {
+	daemon := newCommandTestDaemon()
+	defer daemon.Stop()
+
+	for _, name := range []string{"procs", "load"} {
+		if err := daemon.EnableInstrument(name); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+	}
+
+	if err := daemon.Send(Burst, "50ms", "100ms", "procs"); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if !hasPeriod(daemon, "procs", 50*time.Millisecond) {
+		t.Error("Expected the period of procs to be set during the burst")
+	}
+	if !hasPeriod(daemon, "load", daemon.cfg.Instruments.Periods["load"]) {
+		t.Error("Expected instruments that are not part of the burst to keep their period")
+	}
+
+	if !hasPeriod(daemon, "procs", daemon.cfg.Instruments.Periods["procs"]) {
+		t.Error("Expected the configured period to be restored after the burst")
+	}
+}
+
+func TestDaemon_BurstOverlapping(t *testing.T) {
+	daemon := newCommandTestDaemon()
+	defer daemon.Stop()
+
+	if err := daemon.EnableInstrument("procs"); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	// the shortest period of the active bursts wins, regardless of the order
+	if err := daemon.Burst(nil, 20*time.Millisecond, 150*time.Millisecond); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if err := daemon.Burst([]string{"procs"}, 40*time.Millisecond, time.Hour); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if !hasPeriod(daemon, "procs", 20*time.Millisecond) {
+		t.Error("Expected the shortest period of overlapping bursts")
+	}
+
+	// once the shorter burst ends, the remaining one applies
+	if !hasPeriod(daemon, "procs", 40*time.Millisecond) {
+		t.Error("Expected the period of the remaining burst")
+	}
+
+	// a period set during a burst applies after the burst
+	if err := daemon.SetInstrumentPeriod("procs", 2*time.Second); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if !hasPeriod(daemon, "procs", 40*time.Millisecond) {
+		t.Error("Expected the burst period to be kept")
+	}
+
+	// end the remaining burst early
+	daemon.mutex.Lock()
+	ids := make([]int, 0)
+	for id, b := range daemon.bursts {
+		b.timer.Stop()
+		ids = append(ids, id)
+	}
+	daemon.mutex.Unlock()
+	for _, id := range ids {
+		daemon.endBurst(id)
+	}
+
+	if !hasPeriod(daemon, "procs", 2*time.Second) {
+		t.Error("Expected the period set by command after the burst")
+	}
+}
+
+func TestDaemon_BurstErrors(t *testing.T) {
+	daemon := newCommandTestDaemon()
+	defer daemon.Stop()
+
+	if err := daemon.Send(Burst, "50ms", "1s", "procs"); err == nil {
+		t.Error("Expected an error for an instrument that is not enabled")
+	}
+	if err := daemon.Send(Burst, "50ms"); err == nil {
+		t.Error("Expected an error for a missing duration")
+	}
+	if err := daemon.Send(Burst, "fast", "1s"); err == nil {
+		t.Error("Expected an error for an invalid period")
+	}
+	if err := daemon.Send(Burst, "0s", "1s"); err == nil {
+		t.Error("Expected an error for a period of zero")
+	}
+	if err := daemon.Send(Burst, "1ms", "1s"); err == nil {
+		t.Error("Expected an error for a period below the minimum")
+	}
+	if err := daemon.Send(Burst, "50ms", "24h"); err == nil {
+		t.Error("Expected an error for a duration above the maximum")
+	}
+}
@@ -2,21 +2,33 @@ package telemd
 
 import (
 	"fmt"
+	"github.com/edgerun/telemd/internal/docker"
 	"github.com/edgerun/telemd/internal/env"
+	"github.com/edgerun/telemd/internal/kubelet"
+	"github.com/edgerun/telemd/internal/nl80211"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
-	"strconv"
+	"path/filepath"
 	"strings"
 	"time"
 )
 
 const DefaultConfigPath string = "/etc/telemd/config.ini"
 
+// DefaultYamlConfigPath is read instead of DefaultConfigPath if it exists
+const DefaultYamlConfigPath string = "/etc/telemd/config.yaml"
+
 type Config struct {
 	NodeName string
-	Redis    struct {
+	// WatchConfig reloads the config when the config file changes
+	WatchConfig bool
+	// CentralConfig reads the config from the redis hashes telemd.config and telemd.config:<nodename>
+	CentralConfig bool
+	// Groups are the names of the command groups of the node, see the topics of RedisCommandServer
+	Groups []string
+	Redis  struct {
 		URL          string
 		RetryBackoff time.Duration
 	}
@@ -24,16 +36,134 @@ type Config struct {
 		Enable  []string
 		Disable []string
 		Periods map[string]time.Duration
-		Net     struct {
+		// Timeout is the default deadline of a measurement, Timeouts overrides it per instrument
+		Timeout  time.Duration
+		Timeouts map[string]time.Duration
+		Net      struct {
+			// Devices and Exclude are glob patterns of the monitored devices
 			Devices []string
+			Exclude []string
 		}
 		Disk struct {
 			Devices []string
+			Exclude []string
+		}
+		// DiscoveryInterval is how often net and disk devices are rediscovered
+		DiscoveryInterval time.Duration
+		// Exec maps the names of exec instruments to their shell command
+		Exec     map[string]string
+		Textfile struct {
+			// Dir is the directory of *.prom files, files older than MaxAge are not reported
+			Dir    string
+			MaxAge time.Duration
 		}
 	}
 	Mounts struct {
 		Proc string
 	}
+	Commands struct {
+		// Keys verify signed commands, commands are only accepted if signed when keys are given
+		Keys    []string
+		MaxSkew time.Duration
+	}
+	Ingest struct {
+		// StatsdAddr is the UDP address of the statsd listener, Socket the path of the unix socket for metric lines.
+		// Empty values disable the respective listener.
+		StatsdAddr    string
+		FlushInterval time.Duration
+		Socket        string
+	}
+	Docker struct {
+		Metadata bool
+		Socket   string
+	}
+	Kubelet struct {
+		Metadata        bool
+		URL             string
+		TokenFile       string
+		Insecure        bool
+		RefreshInterval time.Duration
+	}
+	Events struct {
+		Containers         bool
+		ContainersInterval time.Duration
+		// DefaultIfaceInterval is how often the default network interface is re-evaluated
+		DefaultIfaceInterval time.Duration
+	}
+}
+
+// FindConfigPath returns the path of the config file that is read by default: DefaultYamlConfigPath if it exists,
+// otherwise DefaultConfigPath.
+func FindConfigPath() string {
+	if _, err := os.Stat(DefaultYamlConfigPath); err == nil {
+		return DefaultYamlConfigPath
+	}
+	return DefaultConfigPath
+}
+
+// LoadConfig reads the config from the os environment and the config file at the given path, if it exists. The file is
+// read as YAML if its name ends with .yaml or .yml, and as ini file otherwise. Values of the node's section overwrite
+// the ones of the default section. The given central environments (see ReadCentralConfig) overwrite the config file,
+// and the os environment overwrites all of them.
+func LoadConfig(path string, central ...env.Environment) (*Config, error) {
+	cfg := NewDefaultConfig()
+	// load os env first to get potential telemd_nodename
+	if err := cfg.ReadEnvironment(env.OsEnv); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if err := cfg.readConfigFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, e := range central {
+		if err := cfg.ReadEnvironment(e); err != nil {
+			return nil, err
+		}
+	}
+
+	// overwrite file values with os env as per specified behavior
+	if err := cfg.ReadEnvironment(env.OsEnv); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// readConfigFile reads the default section of the config file, and then the section of the node.
+func (cfg *Config) readConfigFile(path string) error {
+	if IsYamlConfig(path) {
+		file, err := readYamlConfig(path)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", path, err)
+		}
+		if err := cfg.ReadEnvironment(env.NewMapEnvironment(file.global)); err != nil {
+			return err
+		}
+		return cfg.ReadEnvironment(env.NewMapEnvironment(file.nodes[cfg.NodeName]))
+	}
+
+	iniEnv, err := env.NewIniEnvironment(path)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", path, err)
+	}
+	if err := cfg.ReadEnvironment(iniEnv); err != nil {
+		return err
+	}
+
+	iniNodeEnv, err := env.NewIniSectionEnvironment(path, cfg.NodeName)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", path, err)
+	}
+	return cfg.ReadEnvironment(iniNodeEnv)
+}
+
+// IsYamlConfig returns whether the config file at the given path is read as YAML.
+func IsYamlConfig(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
 }
 
 func NewConfig() *Config {
@@ -48,49 +178,76 @@ func NewDefaultConfig() *Config {
 	cfg.Redis.URL = "redis://localhost"
 	cfg.Redis.RetryBackoff = 5 * time.Second
 
-	var err error
-	cfg.Instruments.Net.Devices, err = networkDevices()
-	if err != nil {
-		log.Println(err)
-	}
+	cfg.Mounts.Proc = "/proc"
 
-	cfg.Instruments.Disk.Devices, err = blockDevices()
-	if err != nil {
-		log.Println(err)
-	}
-
-	cfg.Instruments.Periods = map[string]time.Duration{
-		"cpu":                    500 * time.Millisecond,
-		"freq":                   500 * time.Millisecond,
-		"procs":                  500 * time.Millisecond,
-		"ram":                    1 * time.Second,
-		"load":                   5 * time.Second,
-		"net":                    500 * time.Millisecond,
-		"disk":                   500 * time.Millisecond,
-		"psi_cpu":                500 * time.Millisecond,
-		"psi_io":                 500 * time.Millisecond,
-		"psi_memory":             500 * time.Millisecond,
-		"tx_bitrate":             1 * time.Second,
-		"rx_bitrate":             1 * time.Second,
-		"signal":                 1 * time.Second,
-		"docker_cgrp_cpu":        1 * time.Second,
-		"docker_cgrp_blkio":      1 * time.Second,
-		"docker_cgrp_net":        1 * time.Second,
-		"docker_cgrp_memory":     1 * time.Second,
-		"kubernetes_cgrp_cpu":    1 * time.Second,
-		"kubernetes_cgrp_blkio":  1 * time.Second,
-		"kubernetes_cgrp_memory": 1 * time.Second,
-		"kubernetes_cgrp_net":    1 * time.Second,
+	cfg.Docker.Socket = docker.DefaultSocket
+
+	cfg.Kubelet.URL = kubelet.DefaultUrl
+	cfg.Kubelet.TokenFile = kubelet.DefaultTokenFile
+	cfg.Kubelet.RefreshInterval = 30 * time.Second
+
+	cfg.Events.Containers = true
+	cfg.Events.ContainersInterval = 1 * time.Second
+	cfg.Events.DefaultIfaceInterval = 5 * time.Second
+
+	cfg.Ingest.FlushInterval = 10 * time.Second
+
+	cfg.Commands.MaxSkew = 30 * time.Second
+
+	cfg.Instruments.DiscoveryInterval = 10 * time.Second
+	cfg.Instruments.Timeout = 5 * time.Second
+	cfg.Instruments.Timeouts = make(map[string]time.Duration)
+	cfg.Instruments.Exec = make(map[string]string)
+	cfg.Instruments.Textfile.Dir = "/var/lib/telemd/textfile"
+	cfg.Instruments.Textfile.MaxAge = 5 * time.Minute
+
+	// the default periods of all registered instruments
+	cfg.Instruments.Periods = make(map[string]time.Duration)
+	for _, spec := range RegisteredInstruments() {
+		cfg.Instruments.Periods[spec.Name] = spec.Period
 	}
 
 	return cfg
 }
 
+// LoadFromEnvironment reads the config from the given environment, and exits if a value cannot be parsed.
 func (cfg *Config) LoadFromEnvironment(env env.Environment) {
+	if err := cfg.ReadEnvironment(env); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// ReadEnvironment reads the config from the given environment. Keys that are not set keep their current value.
+func (cfg *Config) ReadEnvironment(env env.Environment) error {
 
 	if name, ok := env.Lookup("telemd_nodename"); ok {
 		cfg.NodeName = name
 	}
+	if watch, ok, err := env.LookupBool("telemd_config_watch"); err == nil && ok {
+		cfg.WatchConfig = watch
+	} else if err != nil {
+		return readError("telemd_config_watch", err)
+	}
+	if central, ok, err := env.LookupBool("telemd_central_config"); err == nil && ok {
+		cfg.CentralConfig = central
+	} else if err != nil {
+		return readError("telemd_central_config", err)
+	}
+	if groups, ok, err := env.LookupFields("telemd_groups"); err == nil && ok {
+		cfg.Groups = groups
+	} else if err != nil {
+		return readError("telemd_groups", err)
+	}
+	if keys, ok, err := env.LookupFields("telemd_command_keys"); err == nil && ok {
+		cfg.Commands.Keys = keys
+	} else if err != nil {
+		return readError("telemd_command_keys", err)
+	}
+	if skew, ok, err := env.LookupDuration("telemd_command_max_skew"); err == nil && ok {
+		cfg.Commands.MaxSkew = skew
+	} else if err != nil {
+		return readError("telemd_command_max_skew", err)
+	}
 
 	if url, ok := env.Lookup("telemd_redis_url"); ok {
 		cfg.Redis.URL = url
@@ -108,46 +265,219 @@ func (cfg *Config) LoadFromEnvironment(env env.Environment) {
 		}
 	}
 
-	procMount := "/proc"
 	if value, ok := env.Lookup("telemd_proc_mount"); ok {
-		procMount = value
+		cfg.Mounts.Proc = value
+	}
+
+	if enabled, ok, err := env.LookupBool("telemd_docker_metadata"); err == nil && ok {
+		cfg.Docker.Metadata = enabled
+	} else if err != nil {
+		return readError("telemd_docker_metadata", err)
+	}
+	if socket, ok := env.Lookup("telemd_docker_socket"); ok {
+		cfg.Docker.Socket = socket
+	}
+
+	if enabled, ok, err := env.LookupBool("telemd_kubelet_metadata"); err == nil && ok {
+		cfg.Kubelet.Metadata = enabled
+	} else if err != nil {
+		return readError("telemd_kubelet_metadata", err)
+	}
+	if url, ok := env.Lookup("telemd_kubelet_url"); ok {
+		cfg.Kubelet.URL = url
+	}
+	if tokenFile, ok := env.Lookup("telemd_kubelet_token_file"); ok {
+		cfg.Kubelet.TokenFile = tokenFile
+	}
+	if insecure, ok, err := env.LookupBool("telemd_kubelet_insecure"); err == nil && ok {
+		cfg.Kubelet.Insecure = insecure
+	} else if err != nil {
+		return readError("telemd_kubelet_insecure", err)
+	}
+	if interval, ok, err := lookupInterval(env, "telemd_kubelet_refresh_interval"); err == nil && ok {
+		cfg.Kubelet.RefreshInterval = interval
+	} else if err != nil {
+		return readError("telemd_kubelet_refresh_interval", err)
 	}
 
-	cfg.Mounts.Proc = procMount
+	if enabled, ok, err := env.LookupBool("telemd_container_events"); err == nil && ok {
+		cfg.Events.Containers = enabled
+	} else if err != nil {
+		return readError("telemd_container_events", err)
+	}
+	if interval, ok, err := lookupInterval(env, "telemd_container_events_interval"); err == nil && ok {
+		cfg.Events.ContainersInterval = interval
+	} else if err != nil {
+		return readError("telemd_container_events_interval", err)
+	}
+	if interval, ok, err := lookupInterval(env, "telemd_default_iface_interval"); err == nil && ok {
+		cfg.Events.DefaultIfaceInterval = interval
+	} else if err != nil {
+		return readError("telemd_default_iface_interval", err)
+	}
 
 	if devices, ok, err := env.LookupFields("telemd_net_devices"); err == nil && ok {
 		cfg.Instruments.Net.Devices = devices
 	} else if err != nil {
-		log.Fatal("Error reading telemd_net_devices", err)
+		return readError("telemd_net_devices", err)
+	}
+	if devices, ok, err := env.LookupFields("telemd_net_devices_exclude"); err == nil && ok {
+		cfg.Instruments.Net.Exclude = devices
+	} else if err != nil {
+		return readError("telemd_net_devices_exclude", err)
 	}
 	if devices, ok, err := env.LookupFields("telemd_disk_devices"); err == nil && ok {
 		cfg.Instruments.Disk.Devices = devices
 	} else if err != nil {
-		log.Fatal("Error reading telemd_disk_devices", err)
+		return readError("telemd_disk_devices", err)
+	}
+	if devices, ok, err := env.LookupFields("telemd_disk_devices_exclude"); err == nil && ok {
+		cfg.Instruments.Disk.Exclude = devices
+	} else if err != nil {
+		return readError("telemd_disk_devices_exclude", err)
+	}
+	if interval, ok, err := lookupInterval(env, "telemd_device_discovery_interval"); err == nil && ok {
+		cfg.Instruments.DiscoveryInterval = interval
+	} else if err != nil {
+		return readError("telemd_device_discovery_interval", err)
+	}
+
+	if dir, ok := env.Lookup("telemd_textfile_dir"); ok {
+		cfg.Instruments.Textfile.Dir = dir
+	}
+	if age, ok, err := env.LookupDuration("telemd_textfile_max_age"); err == nil && ok {
+		cfg.Instruments.Textfile.MaxAge = age
+	} else if err != nil {
+		return readError("telemd_textfile_max_age", err)
+	}
+
+	if addr, ok := env.Lookup("telemd_statsd_address"); ok {
+		cfg.Ingest.StatsdAddr = addr
+	}
+	if interval, ok, err := lookupInterval(env, "telemd_statsd_flush_interval"); err == nil && ok {
+		cfg.Ingest.FlushInterval = interval
+	} else if err != nil {
+		return readError("telemd_statsd_flush_interval", err)
+	}
+	if path, ok := env.Lookup("telemd_ingest_socket"); ok {
+		cfg.Ingest.Socket = path
+	}
+
+	if err := cfg.loadExecInstruments(env); err != nil {
+		return err
 	}
 
 	for instrument := range cfg.Instruments.Periods {
 		key := "telemd_period_" + instrument
 
-		if duration, ok, err := env.LookupDuration(key); err == nil && ok {
+		if duration, ok, err := lookupInterval(env, key); err == nil && ok {
 			log.Println("setting duration of", instrument, "to", duration)
 			cfg.Instruments.Periods[instrument] = duration
 		} else if err != nil {
-			log.Fatal("Error reading "+key, err)
+			return readError(key, err)
+		}
+	}
+
+	if timeout, ok, err := env.LookupDuration("telemd_instrument_timeout"); err == nil && ok {
+		cfg.Instruments.Timeout = timeout
+	} else if err != nil {
+		return readError("telemd_instrument_timeout", err)
+	}
+
+	for instrument := range cfg.Instruments.Periods {
+		key := "telemd_timeout_" + instrument
+
+		if timeout, ok, err := env.LookupDuration(key); err == nil && ok {
+			log.Println("setting timeout of", instrument, "to", timeout)
+			cfg.Instruments.Timeouts[instrument] = timeout
+		} else if err != nil {
+			return readError(key, err)
 		}
 	}
 
 	if fields, ok, err := env.LookupFields("telemd_instruments_enable"); err == nil && ok {
 		cfg.Instruments.Enable = fields
 	} else if err != nil {
-		log.Fatal("Error reading telemd_instruments_enable", err)
+		return readError("telemd_instruments_enable", err)
 	}
 
 	if fields, ok, err := env.LookupFields("telemd_instruments_disable"); err == nil && ok {
 		cfg.Instruments.Disable = fields
 	} else if err != nil {
-		log.Fatal("Error reading telemd_instruments_disable", err)
+		return readError("telemd_instruments_disable", err)
+	}
+
+	return nil
+}
+
+func readError(key string, err error) error {
+	return ConfigErrors{{key, err.Error()}}
+}
+
+// lookupInterval looks up a duration that is used as period or interval of a ticker, which must be positive.
+func lookupInterval(env env.Environment, key string) (time.Duration, bool, error) {
+	duration, ok, err := env.LookupDuration(key)
+	if err == nil && ok && duration <= 0 {
+		return 0, false, fmt.Errorf("must be positive, got %v", duration)
+	}
+	return duration, ok, err
+}
+
+// isEnabled returns whether the instrument is enabled by telemd_instruments_enable and telemd_instruments_disable.
+func (cfg *Config) isEnabled(instrument string) bool {
+	if len(cfg.Instruments.Disable) > 0 {
+		for _, name := range cfg.Instruments.Disable {
+			if name == instrument {
+				return false
+			}
+		}
+		return true
+	}
+	if len(cfg.Instruments.Enable) > 0 {
+		for _, name := range cfg.Instruments.Enable {
+			if name == instrument {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// loadExecInstruments reads the exec instruments listed in telemd_exec_instruments. An exec instrument <name> is
+// configured by telemd_exec_<name>_command, and optionally telemd_exec_<name>_period and telemd_exec_<name>_timeout.
+// The instrument runs under the name exec_<name>.
+func (cfg *Config) loadExecInstruments(env env.Environment) error {
+	if names, ok, err := env.LookupFields("telemd_exec_instruments"); err == nil && ok {
+		for _, name := range names {
+			if _, ok := cfg.Instruments.Exec[name]; !ok {
+				cfg.Instruments.Exec[name] = ""
+				cfg.Instruments.Periods["exec_"+name] = 10 * time.Second
+			}
+		}
+	} else if err != nil {
+		return readError("telemd_exec_instruments", err)
 	}
+
+	for name := range cfg.Instruments.Exec {
+		prefix := "telemd_exec_" + name
+
+		if command, ok := env.Lookup(prefix + "_command"); ok {
+			cfg.Instruments.Exec[name] = command
+		}
+		if period, ok, err := lookupInterval(env, prefix+"_period"); err == nil && ok {
+			cfg.Instruments.Periods["exec_"+name] = period
+		} else if err != nil {
+			return readError(prefix+"_period", err)
+		}
+		if timeout, ok, err := env.LookupDuration(prefix + "_timeout"); err == nil && ok {
+			cfg.Instruments.Timeouts["exec_"+name] = timeout
+		} else if err != nil {
+			return readError(prefix+"_timeout", err)
+		}
+	}
+
+	return nil
 }
 
 func listFilterDir(dirname string, predicate func(info os.FileInfo) bool) ([]string, error) {
@@ -179,36 +509,39 @@ func blockDevices() ([]string, error) {
 	})
 }
 
-func netSpeed() (string, error) {
-	activeNetDevice, err := findActiveNetDevice()
+// netSpeed returns the link speed of the default network interface, which is determined from the routes of the given
+// proc mount like DefaultIfaceMonitor does. sysNet is the directory of the network interfaces, i.e., /sys/class/net.
+func netSpeed(procMount string, sysNet string) (string, error) {
+	activeNetDevice, err := findDefaultIface(procMount)
 	if err != nil {
 		return "", err
 	}
-	wirelessPath := "/sys/class/net/" + activeNetDevice + "/wireless"
+	wirelessPath := sysNet + "/" + activeNetDevice + "/wireless"
 	if fileDirExists(wirelessPath) {
 		return findWifiSpeed(activeNetDevice)
 	} else {
-		path := "/sys/class/net/" + activeNetDevice + "/speed"
+		path := sysNet + "/" + activeNetDevice + "/speed"
 		return readFirstLine(path)
 	}
 }
 
-func findActiveNetDevice() (string, error) {
-	args := "route | awk 'NR==3{print $8}'"
-	return execCommand(args)
-}
-
 func findWifiSpeed(device string) (string, error) {
-	speed, err := parseIw(device, "tx bitrate", 3)
+	client, err := nl80211.New()
 	if err != nil {
-		return speed, err
+		return "", err
 	}
-	//parse float to int
-	value, err := strconv.ParseFloat(speed, 32)
+	defer client.Close()
+
+	links, err := readWifiLinks(client)
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprint(int(value)), nil
+	for _, link := range links {
+		if link.Interface.Name == device {
+			return fmt.Sprint(int(link.Station.TxBitrate)), nil
+		}
+	}
+	return "", fmt.Errorf("wireless device %s is not connected", device)
 }
 
 func execCommand(args string) (string, error) {
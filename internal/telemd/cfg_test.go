@@ -1,8 +1,13 @@
 package telemd
 
 import (
+	"errors"
 	"github.com/edgerun/telemd/internal/env"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewDefaultApplicationConfig(t *testing.T) {
@@ -26,3 +31,133 @@ func TestApplicationConfig_ReadFromEnvironment(t *testing.T) {
 		t.Error("Expected url to be redis://192.168.99.1:1234, but was", cfg.Redis.URL)
 	}
 }
+
+func TestApplicationConfig_ExecInstruments(t *testing.T) {
+	cfg := NewDefaultConfig()
+	e := env.OsEnv
+
+	e.Set("telemd_exec_instruments", "queues")
+	defer e.Set("telemd_exec_instruments", "")
+	e.Set("telemd_exec_queues_command", "/opt/queues.sh")
+	defer e.Set("telemd_exec_queues_command", "")
+	e.Set("telemd_exec_queues_timeout", "2s")
+	defer e.Set("telemd_exec_queues_timeout", "")
+
+	cfg.LoadFromEnvironment(env.OsEnv)
+
+	if cfg.Instruments.Exec["queues"] != "/opt/queues.sh" {
+		t.Error("Unexpected command", cfg.Instruments.Exec["queues"])
+	}
+	if cfg.Instruments.Periods["exec_queues"] != 10*time.Second {
+		t.Error("Expected default period of 10s, got", cfg.Instruments.Periods["exec_queues"])
+	}
+	if cfg.Instruments.Timeouts["exec_queues"] != 2*time.Second {
+		t.Error("Unexpected timeout", cfg.Instruments.Timeouts["exec_queues"])
+	}
+}
+
+func TestApplicationConfig_Groups(t *testing.T) {
+	cfg := NewDefaultConfig()
+	e := env.OsEnv
+
+	e.Set("telemd_groups", "pis site-a")
+	defer e.Set("telemd_groups", "")
+
+	cfg.LoadFromEnvironment(env.OsEnv)
+
+	if len(cfg.Groups) != 2 || cfg.Groups[0] != "pis" || cfg.Groups[1] != "site-a" {
+		t.Error("Unexpected groups", cfg.Groups)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	hostname, _ := os.Hostname()
+
+	file, err := ioutil.TempFile("", "telemd-config-*.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	_, _ = file.WriteString("telemd_period_procs=2s\ntelemd_period_ram=3s\ntelemd_proc_mount=/proc_host\n\n[" + hostname +
+		"]\ntelemd_period_ram=4s\n")
+	_ = file.Close()
+
+	_ = os.Setenv("telemd_period_load", "5s")
+	defer os.Unsetenv("telemd_period_load")
+
+	cfg, err := LoadConfig(file.Name())
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	if cfg.Instruments.Periods["procs"] != 2*time.Second {
+		t.Error("Expected period of the default section, got", cfg.Instruments.Periods["procs"])
+	}
+	if cfg.Instruments.Periods["ram"] != 4*time.Second {
+		t.Error("Expected period of the node section, got", cfg.Instruments.Periods["ram"])
+	}
+	if cfg.Instruments.Periods["load"] != 5*time.Second {
+		t.Error("Expected period of the os environment, got", cfg.Instruments.Periods["load"])
+	}
+	if cfg.Mounts.Proc != "/proc_host" {
+		t.Error("Expected proc mount of the default section, got", cfg.Mounts.Proc)
+	}
+}
+
+func TestLoadConfig_InvalidValue(t *testing.T) {
+	file, err := ioutil.TempFile("", "telemd-config-*.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	_, _ = file.WriteString("telemd_period_procs=often\n")
+	_ = file.Close()
+
+	_, err = LoadConfig(file.Name())
+	if err == nil || !strings.Contains(err.Error(), "telemd_period_procs") {
+		t.Error("Expected an error naming the key, got", err)
+	}
+}
+
+func TestLoadConfig_NonPositivePeriod(t *testing.T) {
+	file, err := ioutil.TempFile("", "telemd-config-*.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	_, _ = file.WriteString("telemd_period_procs=0s\n")
+	_ = file.Close()
+
+	_, err = LoadConfig(file.Name())
+
+	var errs ConfigErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Key != "telemd_period_procs" {
+		t.Error("Expected a config error naming the key, got", err)
+	}
+}
+
+func TestLoadConfig_Central(t *testing.T) {
+	global := env.NewMapEnvironment(map[string]string{"telemd_period_procs": "2s", "telemd_period_ram": "3s"})
+	node := env.NewMapEnvironment(map[string]string{"telemd_period_ram": "4s", "telemd_period_load": "4s"})
+
+	_ = os.Setenv("telemd_period_load", "5s")
+	defer os.Unsetenv("telemd_period_load")
+
+	cfg, err := LoadConfig("/nonexistent/config.ini", global, node)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	if cfg.Instruments.Periods["procs"] != 2*time.Second {
+		t.Error("Expected period of the global config, got", cfg.Instruments.Periods["procs"])
+	}
+	if cfg.Instruments.Periods["ram"] != 4*time.Second {
+		t.Error("Expected period of the node config, got", cfg.Instruments.Periods["ram"])
+	}
+	if cfg.Instruments.Periods["load"] != 5*time.Second {
+		t.Error("Expected period of the os environment, got", cfg.Instruments.Periods["load"])
+	}
+}
@@ -1,24 +1,44 @@
 package telemd
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"time"
 )
 
 const (
+	// Pause and Unpause all tickers, or the tickers of the instruments given as arguments
 	Pause   Command = "pause"
 	Unpause Command = "unpause"
+	// Enable and Disable the instruments given as arguments
+	Enable  Command = "enable"
+	Disable Command = "disable"
+	// Period sets the period of an instrument, e.g., `period cpu 250ms`
+	Period Command = "period"
+	// Burst temporarily sets the period of instruments, e.g., `burst 50ms 30s cpu net` samples cpu and net every
+	// 50ms for 30 seconds. Without instruments, all enabled instruments are sampled.
+	Burst Command = "burst"
 )
 
 type Command string
 
+type commandRequest struct {
+	command Command
+	args    []string
+	result  chan error
+}
+
 type commandChannel struct {
-	channel chan Command
+	channel chan commandRequest
 	stop    chan bool
 }
 
 func newCommandChannel() *commandChannel {
 	tcc := &commandChannel{
-		channel: make(chan Command),
+		channel: make(chan commandRequest),
 		stop:    make(chan bool),
 	}
 	return tcc
@@ -27,8 +47,8 @@ func newCommandChannel() *commandChannel {
 func (daemon *Daemon) runCommandLoop() {
 	for {
 		select {
-		case cmd := <-daemon.cmds.channel:
-			daemon.handleCommand(cmd)
+		case req := <-daemon.cmds.channel:
+			req.result <- daemon.handleCommand(req.command, req.args)
 		case stop := <-daemon.cmds.stop:
 			if stop {
 				return
@@ -37,31 +57,151 @@ func (daemon *Daemon) runCommandLoop() {
 	}
 }
 
-func (daemon *Daemon) handleCommand(cmd Command) {
+func (daemon *Daemon) handleCommand(cmd Command, args []string) error {
 	switch cmd {
 	case Pause:
-		log.Printf("pausing %d tickers\n", len(daemon.tickers))
-		daemon.isPausedByCommand = true
+		if len(args) > 0 {
+			return forEachInstrument(args, daemon.PauseInstrument)
+		}
+		log.Println("pausing all tickers")
+		daemon.setPausedByCommand(true)
 		daemon.PauseTickers()
 	case Unpause:
-		log.Printf("unpausing %d tickers\n", len(daemon.tickers))
-		daemon.isPausedByCommand = false
+		if len(args) > 0 {
+			return forEachInstrument(args, daemon.UnpauseInstrument)
+		}
+		log.Println("unpausing all tickers")
+		daemon.setPausedByCommand(false)
 		daemon.UnpauseTickers()
+	case Enable:
+		return forEachInstrument(args, daemon.EnableInstrument)
+	case Disable:
+		return forEachInstrument(args, daemon.DisableInstrument)
+	case Period:
+		if len(args) != 2 {
+			return errors.New("usage: period <instrument> <duration>")
+		}
+		period, err := time.ParseDuration(args[1])
+		if err != nil {
+			return err
+		}
+		return daemon.SetInstrumentPeriod(args[0], period)
+	case Burst:
+		if len(args) < 2 {
+			return errors.New("usage: burst <period> <duration> [instrument...]")
+		}
+		period, err := time.ParseDuration(args[0])
+		if err != nil {
+			return err
+		}
+		duration, err := time.ParseDuration(args[1])
+		if err != nil {
+			return err
+		}
+		return daemon.Burst(args[2:], period, duration)
 	default:
-		log.Println("unhandled command", cmd)
+		return errors.New("unhandled command " + string(cmd))
 	}
+	return nil
 }
 
+// forEachInstrument calls f for each of the given instruments, and returns the first error.
+func forEachInstrument(instruments []string, f func(string) error) error {
+	if len(instruments) == 0 {
+		return errors.New("no instrument given")
+	}
+
+	var first error
+	for _, name := range instruments {
+		if err := f(name); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (daemon *Daemon) setPausedByCommand(paused bool) {
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+	daemon.isPausedByCommand = paused
+}
+
+// UnpauseTickers resumes all tickers, except the ones that were paused individually, unless all tickers were paused
+// by command. Before the daemon runs, it only changes the state the tickers are started with.
 func (daemon *Daemon) UnpauseTickers() {
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+
 	if !daemon.isPausedByCommand {
-		for _, ticker := range daemon.tickers {
-			ticker.Unpause()
+		daemon.tickersPaused = false
+		if !daemon.running {
+			return
+		}
+		for name, ticker := range daemon.tickers {
+			if !daemon.paused[name] {
+				ticker.Unpause()
+			}
 		}
 	}
 }
 
+// PauseTickers pauses all tickers. Before the daemon runs, the tickers are started paused instead.
 func (daemon *Daemon) PauseTickers() {
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+
+	daemon.tickersPaused = true
+	if !daemon.running {
+		return
+	}
 	for _, ticker := range daemon.tickers {
 		ticker.Pause()
 	}
 }
+
+// CommandMessage is a command in the JSON protocol, e.g., {"id": "42", "name": "enable", "args": ["psi_cpu"]}. If
+// the message has an id, the result of the command is published as CommandReply.
+type CommandMessage struct {
+	Id   string   `json:"id"`
+	Name string   `json:"name"`
+	Args []string `json:"args"`
+}
+
+// CommandReply is the result of a CommandMessage. Status is either "ok" or "error", in which case Error describes
+// what went wrong. Payload is the result of commands that return data.
+type CommandReply struct {
+	Id      string      `json:"id"`
+	Status  string      `json:"status"`
+	Error   string      `json:"error,omitempty"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+func NewCommandReply(id string, payload interface{}, err error) CommandReply {
+	if err != nil {
+		return CommandReply{Id: id, Status: "error", Error: err.Error()}
+	}
+	return CommandReply{Id: id, Status: "ok", Payload: payload}
+}
+
+// ParseCommandMessage parses either a JSON command, or a plain-string command where the first word is the name of
+// the command and the remaining words are its arguments, e.g., `period cpu 250ms`.
+func ParseCommandMessage(payload string) (CommandMessage, error) {
+	payload = strings.TrimSpace(payload)
+
+	if strings.HasPrefix(payload, "{") {
+		var msg CommandMessage
+		if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+			return msg, fmt.Errorf("invalid command: %v", err)
+		}
+		if msg.Name == "" {
+			return msg, errors.New("command has no name")
+		}
+		return msg, nil
+	}
+
+	fields := strings.Fields(payload)
+	if len(fields) == 0 {
+		return CommandMessage{}, errors.New("empty command")
+	}
+	return CommandMessage{Name: fields[0], Args: fields[1:]}, nil
+}
@@ -0,0 +1,198 @@
+package telemd
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/edgerun/telemd/internal/telem"
+	"testing"
+	"time"
+)
+
+// newCommandTestDaemon returns a running daemon without instruments, whose telemetry is discarded.
+func newCommandTestDaemon() *Daemon {
+	daemon := newStoppedCommandTestDaemon()
+	daemon.startTickers()
+	return daemon
+}
+
+// newStoppedCommandTestDaemon returns a daemon that runs the command loop, but has not started its tickers yet.
+func newStoppedCommandTestDaemon() *Daemon {
+	daemon := &Daemon{
+		cfg:         NewDefaultConfig(),
+		telemetry:   telem.NewTelemetryChannel(),
+		cmds:        newCommandChannel(),
+		instruments: make(map[string]ContextInstrument),
+		tickers:     make(map[string]TelemetryTicker),
+		paused:      make(map[string]bool),
+		periods:     make(map[string]time.Duration),
+		bursts:      make(map[int]*burst),
+		done:        make(chan struct{}),
+		self:        NewSelfStats(),
+		values:      NewLastValueCache(),
+	}
+
+	go func() {
+		for range daemon.telemetry.Channel() {
+		}
+	}()
+	go daemon.runCommandLoop()
+
+	return daemon
+}
+
+// waitForStats polls the stats of the instrument until the condition is true.
+func waitForStats(daemon *Daemon, name string, condition func(stats InstrumentStats) bool) bool {
+	for i := 0; i < 100; i++ {
+		if stats, ok := daemon.InstrumentStats()[name]; ok && condition(stats) {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestDaemon_EnableDisableCommands(t *testing.T) {
+	daemon := newCommandTestDaemon()
+	defer daemon.Stop()
+
+	if err := daemon.Send(Enable, "procs"); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if err := daemon.Send(Enable, "procs"); err == nil {
+		t.Error("Expected an error when enabling an enabled instrument")
+	}
+	if err := daemon.Send(Enable, "no_such_instrument"); err == nil {
+		t.Error("Expected an error when enabling an unknown instrument")
+	}
+
+	if !waitForStats(daemon, "procs", func(stats InstrumentStats) bool { return stats.Runs > 0 }) {
+		t.Error("Expected the enabled instrument to run")
+	}
+
+	if err := daemon.Send(Disable, "procs"); err != nil {
+		t.Error("Unexpected error", err)
+	}
+	if _, ok := daemon.InstrumentStats()["procs"]; ok {
+		t.Error("Expected the disabled instrument to be removed")
+	}
+	if err := daemon.Send(Disable, "procs"); err == nil {
+		t.Error("Expected an error when disabling a disabled instrument")
+	}
+}
+
+func TestDaemon_PeriodAndPauseCommands(t *testing.T) {
+	daemon := newCommandTestDaemon()
+	defer daemon.Stop()
+
+	if err := daemon.Send(Enable, "procs"); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	if err := daemon.Send(Period, "procs", "10ms"); err != nil {
+		t.Error("Unexpected error", err)
+	}
+	if !waitForStats(daemon, "procs", func(stats InstrumentStats) bool { return stats.Period == 10*time.Millisecond }) {
+		t.Error("Expected the period to be changed")
+	}
+	if err := daemon.Send(Period, "procs", "soon"); err == nil {
+		t.Error("Expected an error for an invalid period")
+	}
+	if err := daemon.Send(Period, "procs"); err == nil {
+		t.Error("Expected an error for a missing period")
+	}
+	if err := daemon.Send(Period, "procs", "1ms"); err == nil {
+		t.Error("Expected an error for a period below the minimum")
+	}
+
+	if err := daemon.Send(Pause, "procs"); err != nil {
+		t.Error("Unexpected error", err)
+	}
+	if !waitForStats(daemon, "procs", func(stats InstrumentStats) bool { return stats.Paused }) {
+		t.Error("Expected the instrument to be paused")
+	}
+
+	// resuming all tickers keeps the individually paused instrument paused
+	daemon.UnpauseTickers()
+	if !waitForStats(daemon, "procs", func(stats InstrumentStats) bool { return stats.Paused }) {
+		t.Error("Expected the instrument to stay paused")
+	}
+
+	if err := daemon.Send(Unpause, "procs"); err != nil {
+		t.Error("Unexpected error", err)
+	}
+	if !waitForStats(daemon, "procs", func(stats InstrumentStats) bool { return !stats.Paused }) {
+		t.Error("Expected the instrument to be unpaused")
+	}
+}
+
+func TestDaemon_PauseTickersBeforeRunning(t *testing.T) {
+	daemon := newStoppedCommandTestDaemon()
+	defer daemon.Stop()
+
+	if err := daemon.Send(Enable, "procs"); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	// the tickers do not run yet, so these must not block
+	daemon.UnpauseTickers()
+	daemon.PauseTickers()
+
+	daemon.startTickers()
+	if !waitForStats(daemon, "procs", func(stats InstrumentStats) bool { return stats.Paused }) {
+		t.Error("Expected the instrument to be started paused")
+	}
+
+	daemon.UnpauseTickers()
+	if !waitForStats(daemon, "procs", func(stats InstrumentStats) bool { return !stats.Paused }) {
+		t.Error("Expected the instrument to be unpaused")
+	}
+}
+
+func TestParseCommandMessage(t *testing.T) {
+	cmd, err := ParseCommandMessage("period cpu 250ms")
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if cmd.Id != "" || cmd.Name != "period" || len(cmd.Args) != 2 || cmd.Args[0] != "cpu" || cmd.Args[1] != "250ms" {
+		t.Error("Unexpected command", cmd)
+	}
+
+	cmd, err = ParseCommandMessage(`{"id": "42", "name": "enable", "args": ["psi_cpu", "psi_io"]}`)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if cmd.Id != "42" || cmd.Name != "enable" || len(cmd.Args) != 2 || cmd.Args[1] != "psi_io" {
+		t.Error("Unexpected command", cmd)
+	}
+
+	cmd, err = ParseCommandMessage(`{"id": "43"}`)
+	if err == nil {
+		t.Error("Expected an error for a command without name")
+	}
+	if cmd.Id != "43" {
+		t.Error("Expected the id of an invalid command to be parsed, so it can be replied to")
+	}
+
+	for _, payload := range []string{"", "  ", `{"id": 42`} {
+		if _, err := ParseCommandMessage(payload); err == nil {
+			t.Errorf("Expected an error for %q", payload)
+		}
+	}
+}
+
+func TestNewCommandReply(t *testing.T) {
+	data, _ := json.Marshal(NewCommandReply("42", nil, nil))
+	if string(data) != `{"id":"42","status":"ok"}` {
+		t.Error("Unexpected reply", string(data))
+	}
+
+	data, _ = json.Marshal(NewCommandReply("42", nil, errors.New("instrument cpu is not enabled")))
+	if string(data) != `{"id":"42","status":"error","error":"instrument cpu is not enabled"}` {
+		t.Error("Unexpected reply", string(data))
+	}
+
+	data, _ = json.Marshal(NewCommandReply("42", map[string]int{"cpu": 1}, nil))
+	if string(data) != `{"id":"42","status":"ok","payload":{"cpu":1}}` {
+		t.Error("Unexpected reply", string(data))
+	}
+}
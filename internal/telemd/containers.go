@@ -0,0 +1,201 @@
+package telemd
+
+import (
+	"context"
+	"github.com/edgerun/telemd/internal/docker"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContainerMetadataCache keeps the metadata (name, image, labels, init pid) of running docker containers that is
+// resolved via the Docker Engine API. Entries are invalidated by listening to the docker event stream.
+type ContainerMetadataCache struct {
+	client       *docker.Client
+	retryBackoff time.Duration
+
+	mutex      sync.RWMutex
+	containers map[string]*docker.Container
+	listeners  []func()
+	observers  []func(docker.Event)
+}
+
+func NewContainerMetadataCache(client *docker.Client, retryBackoff time.Duration) *ContainerMetadataCache {
+	return &ContainerMetadataCache{
+		client:       client,
+		retryBackoff: retryBackoff,
+		containers:   make(map[string]*docker.Container),
+	}
+}
+
+// OnChange registers a function that is called every time the cached metadata changes.
+func (cache *ContainerMetadataCache) OnChange(listener func()) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.listeners = append(cache.listeners, listener)
+}
+
+// OnEvent registers a function that is called for every container event received from the docker daemon.
+func (cache *ContainerMetadataCache) OnEvent(observer func(docker.Event)) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.observers = append(cache.observers, observer)
+}
+
+// Lookup returns the cached metadata of the container with the given full or short (12 characters) id.
+func (cache *ContainerMetadataCache) Lookup(id string) (*docker.Container, bool) {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+
+	if container, ok := cache.containers[id]; ok {
+		return container, true
+	}
+
+	for fullId, container := range cache.containers {
+		if strings.HasPrefix(fullId, id) {
+			return container, true
+		}
+	}
+
+	return nil, false
+}
+
+// Containers returns a copy of all cached container metadata.
+func (cache *ContainerMetadataCache) Containers() []docker.Container {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+
+	containers := make([]docker.Container, 0, len(cache.containers))
+	for _, container := range cache.containers {
+		containers = append(containers, *container)
+	}
+	return containers
+}
+
+// ContainerPids returns a map of full container ids to the pid of the container's init process.
+func (cache *ContainerMetadataCache) ContainerPids() map[string]string {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+
+	pids := make(map[string]string, len(cache.containers))
+	for id, container := range cache.containers {
+		if container.Pid > 0 {
+			pids[id] = strconv.Itoa(container.Pid)
+		}
+	}
+	return pids
+}
+
+// Refresh re-reads the metadata of all running containers.
+func (cache *ContainerMetadataCache) Refresh(ctx context.Context) error {
+	ids, err := cache.client.ContainerIds(ctx)
+	if err != nil {
+		return err
+	}
+
+	containers := make(map[string]*docker.Container, len(ids))
+	for _, id := range ids {
+		container, err := cache.client.Inspect(ctx, id)
+		if err != nil {
+			log.Println("error inspecting container", id, err)
+			continue
+		}
+		containers[container.Id] = container
+	}
+
+	cache.mutex.Lock()
+	cache.containers = containers
+	cache.mutex.Unlock()
+
+	cache.notify()
+	return nil
+}
+
+// Run keeps the cache up to date by listening to the docker event stream until the given channel is closed. If the
+// stream breaks, the cache is re-populated after the configured backoff.
+func (cache *ContainerMetadataCache) Run(done <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-done
+		cancel()
+	}()
+
+	for {
+		if err := cache.Refresh(ctx); err != nil {
+			log.Println("error reading container metadata from docker api", err)
+		} else {
+			cache.watch(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cache.retryBackoff):
+		}
+	}
+}
+
+func (cache *ContainerMetadataCache) watch(ctx context.Context) {
+	events, errs := cache.client.Events(ctx)
+
+	for event := range events {
+		cache.handleEvent(ctx, event)
+	}
+
+	if err := <-errs; err != nil && ctx.Err() == nil {
+		log.Println("docker event stream closed", err)
+	}
+}
+
+func (cache *ContainerMetadataCache) handleEvent(ctx context.Context, event docker.Event) {
+	cache.mutex.RLock()
+	observers := cache.observers
+	cache.mutex.RUnlock()
+
+	for _, observer := range observers {
+		observer(event)
+	}
+
+	switch event.Action {
+	case "destroy":
+		cache.mutex.Lock()
+		delete(cache.containers, event.Id)
+		cache.mutex.Unlock()
+	case "start", "restart", "die", "rename", "update":
+		container, err := cache.client.Inspect(ctx, event.Id)
+		if err != nil {
+			log.Println("error inspecting container", event.Id, err)
+			return
+		}
+		cache.mutex.Lock()
+		cache.containers[container.Id] = container
+		cache.mutex.Unlock()
+	default:
+		return
+	}
+
+	cache.notify()
+}
+
+func (cache *ContainerMetadataCache) notify() {
+	cache.mutex.RLock()
+	listeners := cache.listeners
+	cache.mutex.RUnlock()
+
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
+// dockerContainerPids returns a map of full container ids to a pid of a process running in the container. It uses the
+// metadata cache if available, and falls back to scanning the cgroups of all processes in the given proc mount.
+func dockerContainerPids(procMount string, containers *ContainerMetadataCache) (map[string]string, error) {
+	if containers != nil {
+		return containers.ContainerPids(), nil
+	}
+	return containerProcessIds(procMount)
+}
@@ -1,9 +1,14 @@
 package telemd
 
 import (
+	"errors"
+	"github.com/edgerun/telemd/internal/docker"
+	"github.com/edgerun/telemd/internal/kubelet"
+	"github.com/edgerun/telemd/internal/nl80211"
 	"github.com/edgerun/telemd/internal/telem"
 	"log"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 )
@@ -13,104 +18,329 @@ type Daemon struct {
 	cmds              *commandChannel
 	isPausedByCommand bool
 	telemetry         telem.TelemetryChannel
-	instruments       map[string]Instrument
+	events            telem.EventChannel
+	instruments       map[string]ContextInstrument
+	containers        *ContainerMetadataCache
+	pods              *PodMetadataResolver
+	lifecycle         *ContainerLifecycleMonitor
+	defaultIface      *DefaultIfaceMonitor
+	netDevices        *DeviceSet
+	diskDevices       *DeviceSet
+	wifi              *nl80211.Client
+	statsd            *StatsdServer
+	lines             *LineServer
+	done              chan struct{}
+	self              *SelfStats
+	values            *LastValueCache
 
-	tickers map[string]TelemetryTicker
+	// mutex guards the instruments and tickers, which can be changed by commands while the daemon is running
+	mutex         sync.Mutex
+	tickers       map[string]TelemetryTicker
+	tickersWg     sync.WaitGroup
+	running       bool
+	tickersPaused bool
+	// paused are the instruments that were paused individually by command
+	paused map[string]bool
+	// periods are the periods set by command, which override the configured ones
+	periods   map[string]time.Duration
+	bursts    map[int]*burst
+	nextBurst int
 }
 
 func NewDaemon(cfg *Config) *Daemon {
 	td := &Daemon{
 		cfg:       cfg,
 		telemetry: telem.NewTelemetryChannel(),
+		events:    telem.NewEventChannel(),
 		cmds:      newCommandChannel(),
 		tickers:   make(map[string]TelemetryTicker),
+		paused:    make(map[string]bool),
+		periods:   make(map[string]time.Duration),
+		bursts:    make(map[int]*burst),
+		done:      make(chan struct{}),
+		self:      NewSelfStats(),
+		values:    NewLastValueCache(),
 	}
 
-	td.initInstruments(NewInstrumentFactory(runtime.GOARCH))
+	if cfg.Docker.Metadata {
+		log.Println("resolving container metadata via", cfg.Docker.Socket)
+		td.containers = NewContainerMetadataCache(docker.NewClient(cfg.Docker.Socket), cfg.Redis.RetryBackoff)
+	}
+
+	if cfg.Kubelet.Metadata {
+		log.Println("resolving pod metadata via", cfg.Kubelet.URL)
+		client := kubelet.NewClient(cfg.Kubelet.URL, cfg.Kubelet.TokenFile, cfg.Kubelet.Insecure)
+		td.pods = NewPodMetadataResolver(client, cfg.Kubelet.RefreshInterval)
+	}
+
+	if cfg.Events.Containers {
+		td.lifecycle = NewContainerLifecycleMonitor(td.events, cfg.Events.ContainersInterval, checkCgroup(), td.containers, td.pods)
+	}
+
+	td.netDevices = NewDeviceSet("net", networkDevices, cfg.Instruments.Net.Devices, cfg.Instruments.Net.Exclude,
+		cfg.Instruments.DiscoveryInterval, td.events)
+	td.diskDevices = NewDeviceSet("disk", blockDevices, cfg.Instruments.Disk.Devices, cfg.Instruments.Disk.Exclude,
+		cfg.Instruments.DiscoveryInterval, td.events)
+
+	if cfg.Ingest.StatsdAddr != "" {
+		td.statsd = NewStatsdServer(cfg.Ingest.StatsdAddr, cfg.Ingest.FlushInterval, td.reportChannel())
+	}
+	if cfg.Ingest.Socket != "" {
+		td.lines = NewLineServer(cfg.Ingest.Socket, td.reportChannel())
+	}
+
+	td.defaultIface = NewDefaultIfaceMonitor(td.events, cfg.Events.DefaultIfaceInterval, cfg.Mounts.Proc)
+
+	if hasWirelessDevice() {
+		client, err := nl80211.New()
+		if err != nil {
+			log.Println("not reporting wifi telemetry:", err)
+		} else {
+			td.wifi = client
+		}
+	}
+
+	td.initInstruments()
 	td.initTickers()
 
 	return td
 }
 
-func (daemon *Daemon) initInstruments(factory InstrumentFactory) {
-	cfg := daemon.cfg
-
-	instruments := map[string]Instrument{
-		"cpu":                    factory.NewCpuUtilInstrument(),
-		"freq":                   factory.NewCpuFrequencyInstrument(),
-		"load":                   factory.NewLoadInstrument(),
-		"procs":                  factory.NewProcsInstrument(),
-		"ram":                    factory.NewRamInstrument(),
-		"net":                    factory.NewNetworkDataRateInstrument(cfg.Instruments.Net.Devices),
-		"disk":                   factory.NewDiskDataRateInstrument(cfg.Instruments.Disk.Devices),
-		"psi_cpu":                factory.NewPsiCpuInstrument(),
-		"psi_memory":             factory.NewPsiMemoryInstrument(),
-		"psi_io":                 factory.NewPsiIoInstrument(),
-		"docker_cgrp_cpu":        factory.NewDockerCgroupCpuInstrument(),
-		"docker_cgrp_blkio":      factory.NewDockerCgroupBlkioInstrument(),
-		"docker_cgrp_net":        factory.NewDockerCgroupNetworkInstrument(cfg.Mounts.Proc),
-		"docker_cgrp_memory":     factory.NewDockerCgroupMemoryInstrument(),
-		"kubernetes_cgrp_cpu":    factory.NewKubernetesCgroupCpuInstrument(),
-		"kubernetes_cgrp_blkio":  factory.NewKubernetesCgroupBlkioInstrument(),
-		"kubernetes_cgrp_memory": factory.NewKubernetesCgroupMemoryInstrument(),
-		"kubernetes_cgrp_net":    factory.NewKubernetesCgroupNetInstrument(cfg.Mounts.Proc),
-	}
-
-	activeNetDevice, err := findActiveNetDevice()
-	if err == nil {
-		wirelessPath := "/sys/class/net/" + activeNetDevice + "/wireless"
-		if fileDirExists(wirelessPath) {
-			instruments["tx_bitrate"] = factory.NewWifiTxBitrateInstrument(activeNetDevice)
-			instruments["rx_bitrate"] = factory.NewWifiRxBitrateInstrument(activeNetDevice)
-			instruments["signal"] = factory.NewWifiSignalInstrument(activeNetDevice)
-		}
+// instrumentEnv returns the resources of the daemon that instruments are constructed with.
+func (daemon *Daemon) instrumentEnv() *InstrumentEnv {
+	return &InstrumentEnv{
+		Config:      daemon.cfg,
+		Arch:        runtime.GOARCH,
+		Cgroup:      checkCgroup(),
+		NetDevices:  daemon.netDevices,
+		DiskDevices: daemon.diskDevices,
+		Containers:  daemon.containers,
+		Wifi:        daemon.wifi,
+		Self:        daemon.self,
+		Stats:       daemon.InstrumentStats,
 	}
+}
+
+func (daemon *Daemon) initInstruments() {
+	cfg := daemon.cfg
+	env := daemon.instrumentEnv()
 
-	if cfg.Instruments.Disable != nil && (len(cfg.Instruments.Disable) > 0) {
+	if len(cfg.Instruments.Disable) > 0 {
 		log.Println("disabling instruments", cfg.Instruments.Disable)
-		for _, instr := range cfg.Instruments.Disable {
-			delete(instruments, instr)
-		}
-		daemon.instruments = instruments
-	} else if cfg.Instruments.Enable != nil && (len(cfg.Instruments.Enable) > 0) {
+	} else if len(cfg.Instruments.Enable) > 0 {
 		log.Println("enabling instruments", cfg.Instruments.Enable)
-		daemon.instruments = make(map[string]Instrument, len(cfg.Instruments.Enable))
+	}
 
-		for _, key := range cfg.Instruments.Enable {
-			if value, ok := instruments[key]; ok {
-				daemon.instruments[key] = value
+	daemon.instruments = make(map[string]ContextInstrument)
+
+	names := make([]string, 0)
+	for _, spec := range RegisteredInstruments() {
+		names = append(names, spec.Name)
+	}
+	for name := range cfg.Instruments.Exec {
+		names = append(names, "exec_"+name)
+	}
+
+	for _, name := range names {
+		if !cfg.isEnabled(name) {
+			continue
+		}
+		instrument, err := daemon.newInstrument(name, env)
+		if err != nil {
+			// only worth mentioning if the instrument was enabled explicitly
+			if len(cfg.Instruments.Enable) > 0 {
+				log.Println(err)
 			}
+			continue
 		}
-	} else {
-		daemon.instruments = instruments
+		daemon.instruments[name] = instrument
 	}
 }
 
-func (daemon *Daemon) initTickers() {
-	for k, instrument := range daemon.instruments {
-		period, ok := daemon.cfg.Instruments.Periods[k]
+// newInstrument creates the registered or exec instrument with the given name.
+func (daemon *Daemon) newInstrument(name string, env *InstrumentEnv) (ContextInstrument, error) {
+	if strings.HasPrefix(name, "exec_") {
+		command, ok := daemon.cfg.Instruments.Exec[strings.TrimPrefix(name, "exec_")]
 		if !ok {
-			log.Println("warning: no period assigned for instrument", k, "using 1")
-			period = 1 * time.Second
+			return nil, errors.New("unknown instrument " + name)
 		}
-		ticker := NewTelemetryTicker(instrument, daemon.telemetry, period)
-		daemon.tickers[k] = ticker
+		if command == "" {
+			return nil, errors.New("exec instrument " + name + " has no command, set telemd_" + name + "_command")
+		}
+		return ExecInstrument{command}, nil
+	}
+
+	spec, ok := LookupInstrument(name)
+	if !ok {
+		return nil, errors.New("unknown instrument " + name)
 	}
+	if !spec.IsAvailable(env) {
+		return nil, errors.New("instrument " + name + " is not available on this host")
+	}
+	instrument := spec.New(env)
+	if instrument == nil {
+		return nil, errors.New("instrument " + name + " cannot run with the current configuration")
+	}
+	return instrument, nil
+}
+
+func (daemon *Daemon) initTickers() {
+	for name, instrument := range daemon.instruments {
+		daemon.tickers[name] = daemon.newTicker(name, instrument)
+	}
+}
+
+func (daemon *Daemon) newTicker(name string, instrument ContextInstrument) TelemetryTicker {
+	period, ok := daemon.cfg.Instruments.Periods[name]
+	if !ok {
+		log.Println("warning: no period assigned for instrument", name, "using 1")
+		period = 1 * time.Second
+	}
+	return NewTelemetryTicker(instrument, daemon.reportChannel(), period, daemon.cfg.timeout(name))
 }
 
+// startTickers starts all tickers, and returns a WaitGroup that is done once all tickers have stopped, including the
+// ones that are enabled while the daemon is running.
 func (daemon *Daemon) startTickers() *sync.WaitGroup {
-	var wg sync.WaitGroup
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
 
-	// start tickers and add to wait group
-	for _, ticker := range daemon.tickers {
-		go func(t TelemetryTicker) {
-			wg.Add(1)
-			t.Run()
-			wg.Done()
-		}(ticker)
+	daemon.running = true
+	for name, ticker := range daemon.tickers {
+		daemon.startTicker(ticker)
+		if daemon.tickersPaused || daemon.paused[name] {
+			ticker.Pause()
+		}
 	}
 
-	return &wg
+	return &daemon.tickersWg
+}
+
+func (daemon *Daemon) startTicker(ticker TelemetryTicker) {
+	daemon.tickersWg.Add(1)
+	go func() {
+		ticker.Run()
+		daemon.tickersWg.Done()
+	}()
+}
+
+// EnableInstrument creates the instrument with the given name and starts its ticker.
+func (daemon *Daemon) EnableInstrument(name string) error {
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+	return daemon.enableInstrument(name)
+}
+
+// enableInstrument implements EnableInstrument, the daemon's mutex must be held.
+func (daemon *Daemon) enableInstrument(name string) error {
+	if _, ok := daemon.tickers[name]; ok {
+		return errors.New("instrument " + name + " is already enabled")
+	}
+
+	instrument, err := daemon.newInstrument(name, daemon.instrumentEnv())
+	if err != nil {
+		return err
+	}
+
+	ticker := daemon.newTicker(name, instrument)
+	daemon.instruments[name] = instrument
+	daemon.tickers[name] = ticker
+
+	if daemon.running {
+		daemon.startTicker(ticker)
+		if daemon.tickersPaused || daemon.paused[name] {
+			ticker.Pause()
+		}
+		// the instrument may be part of an active burst
+		daemon.applyPeriod(name)
+	}
+
+	log.Println("enabled instrument", name)
+	return nil
+}
+
+// DisableInstrument stops the ticker of the instrument with the given name.
+func (daemon *Daemon) DisableInstrument(name string) error {
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+	return daemon.disableInstrument(name)
+}
+
+// disableInstrument implements DisableInstrument, the daemon's mutex must be held.
+func (daemon *Daemon) disableInstrument(name string) error {
+	ticker, ok := daemon.tickers[name]
+	if !ok {
+		return errors.New("instrument " + name + " is not enabled")
+	}
+
+	if daemon.running {
+		ticker.Stop()
+	}
+	delete(daemon.tickers, name)
+	delete(daemon.instruments, name)
+	delete(daemon.periods, name)
+
+	log.Println("disabled instrument", name)
+	return nil
+}
+
+// SetInstrumentPeriod changes the period of the running instrument with the given name. The configured period is
+// used again once the instrument is enabled anew. During a burst, the period takes effect once the burst has ended.
+func (daemon *Daemon) SetInstrumentPeriod(name string, period time.Duration) error {
+	if err := checkCommandPeriod(period); err != nil {
+		return err
+	}
+
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+
+	_, ok := daemon.tickers[name]
+	if !ok {
+		return errors.New("instrument " + name + " is not enabled")
+	}
+	if !daemon.running {
+		return errors.New("daemon is not running")
+	}
+
+	daemon.periods[name] = period
+	daemon.applyPeriod(name)
+	log.Println("setting period of", name, "to", period)
+	return nil
+}
+
+// PauseInstrument pauses the ticker of the instrument with the given name until UnpauseInstrument is called.
+func (daemon *Daemon) PauseInstrument(name string) error {
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+
+	ticker, ok := daemon.tickers[name]
+	if !ok {
+		return errors.New("instrument " + name + " is not enabled")
+	}
+
+	daemon.paused[name] = true
+	if daemon.running {
+		ticker.Pause()
+	}
+	return nil
+}
+
+// UnpauseInstrument resumes an instrument paused by PauseInstrument. The ticker stays paused while all tickers are
+// paused.
+func (daemon *Daemon) UnpauseInstrument(name string) error {
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+
+	ticker, ok := daemon.tickers[name]
+	if !ok {
+		return errors.New("instrument " + name + " is not enabled")
+	}
+
+	delete(daemon.paused, name)
+	if daemon.running && !daemon.tickersPaused {
+		ticker.Unpause()
+	}
+	return nil
 }
 
 func (daemon *Daemon) Run() {
@@ -129,23 +359,113 @@ func (daemon *Daemon) Run() {
 		wg.Done()
 	}()
 
+	// keep container metadata up to date
+	if daemon.containers != nil {
+		wg.Add(1)
+		go func() {
+			daemon.containers.Run(daemon.done)
+			wg.Done()
+		}()
+	}
+
+	// keep pod metadata up to date
+	if daemon.pods != nil {
+		wg.Add(1)
+		go func() {
+			daemon.pods.Run(daemon.done)
+			wg.Done()
+		}()
+	}
+
+	// report container lifecycle events
+	if daemon.lifecycle != nil {
+		wg.Add(1)
+		go func() {
+			daemon.lifecycle.Run(daemon.done)
+			wg.Done()
+		}()
+	}
+
+	// follow default network interface changes
+	wg.Add(1)
+	go func() {
+		daemon.defaultIface.Run(daemon.done)
+		wg.Done()
+	}()
+
+	// rediscover hot-plugged devices
+	wg.Add(2)
+	go func() {
+		daemon.netDevices.Run(daemon.done)
+		wg.Done()
+	}()
+	go func() {
+		daemon.diskDevices.Run(daemon.done)
+		wg.Done()
+	}()
+
+	// receive metrics of local applications
+	if daemon.statsd != nil {
+		wg.Add(1)
+		go func() {
+			daemon.statsd.Run(daemon.done)
+			wg.Done()
+		}()
+	}
+	if daemon.lines != nil {
+		wg.Add(1)
+		go func() {
+			daemon.lines.Run(daemon.done)
+			wg.Done()
+		}()
+	}
+
 	wg.Wait()
 	time.Sleep(1 * time.Second) // TODO: properly wait for all tickers to exit
 	log.Println("closing telemetry channel")
 	daemon.telemetry.Close()
+	daemon.events.Close()
+
+	if daemon.wifi != nil {
+		_ = daemon.wifi.Close()
+	}
 }
 
-func (daemon *Daemon) Send(command Command) {
-	daemon.cmds.channel <- command
+// reportChannel returns the channel that instruments and local applications put their telemetry into.
+func (daemon *Daemon) reportChannel() telem.TelemetryChannel {
+	return daemon.self.trackBacklog(daemon.values.Track(daemon.telemetry), "redis")
+}
+
+// Snapshot returns the latest value of every topic that was reported within the given duration.
+func (daemon *Daemon) Snapshot(maxAge time.Duration) []telem.Telemetry {
+	return daemon.values.Snapshot(maxAge)
+}
+
+// SelfStats returns the counters of the daemon's self-telemetry.
+func (daemon *Daemon) SelfStats() *SelfStats {
+	return daemon.self
+}
+
+// Send executes the command with the given arguments in the command loop of the daemon, and returns the result.
+func (daemon *Daemon) Send(command Command, args ...string) error {
+	result := make(chan error, 1)
+	daemon.cmds.channel <- commandRequest{command, args, result}
+	return <-result
 }
 
 func (daemon *Daemon) Stop() {
 	// stop accepting Daemon channel
 	daemon.cmds.stop <- true
+	close(daemon.done)
 
 	// stop tickers
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+
+	daemon.stopBursts()
 	for k, ticker := range daemon.tickers {
 		log.Println("stopping ticker " + k)
 		ticker.Stop()
 	}
+	daemon.running = false
 }
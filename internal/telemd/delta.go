@@ -0,0 +1,63 @@
+package telemd
+
+import "time"
+
+// counterSample is a reading of monotonically increasing counters at a point in time.
+type counterSample struct {
+	values []int64
+	time   time.Time
+}
+
+// counterRates keeps the previous sample of counters by key (e.g., a device), and calculates the per-second rates
+// between two consecutive samples. This way, the window of a rate instrument is exactly the time between two ticks,
+// rather than a fixed time the instrument sleeps between two readings.
+type counterRates struct {
+	previous map[string]counterSample
+}
+
+func newCounterRates() *counterRates {
+	return &counterRates{previous: make(map[string]counterSample)}
+}
+
+// update records the given counter values of the key, and returns the per-second rates since the previous update.
+// It returns false if there is no previous sample, or if a counter was reset in the meantime.
+func (c *counterRates) update(key string, now time.Time, values ...int64) ([]float64, bool) {
+	then, ok := c.previous[key]
+	c.previous[key] = counterSample{values: values, time: now}
+
+	if !ok || len(then.values) != len(values) {
+		return nil, false
+	}
+
+	elapsed := now.Sub(then.time).Seconds()
+	if elapsed <= 0 {
+		return nil, false
+	}
+
+	rates := make([]float64, len(values))
+	for i := range values {
+		delta := values[i] - then.values[i]
+		if delta < 0 {
+			return nil, false // counter was reset (e.g., device re-attached)
+		}
+		rates[i] = float64(delta) / elapsed
+	}
+
+	return rates, true
+}
+
+// retain forgets the samples of all keys that are not in the given list.
+func (c *counterRates) retain(keys []string) {
+	for key := range c.previous {
+		found := false
+		for _, k := range keys {
+			if k == key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			delete(c.previous, key)
+		}
+	}
+}
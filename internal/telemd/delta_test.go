@@ -0,0 +1,33 @@
+package telemd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCounterRates_update(t *testing.T) {
+	rates := newCounterRates()
+	then := time.Unix(1600000000, 0)
+
+	if _, ok := rates.update("eth0", then, 1000, 2000); ok {
+		t.Error("expected no rates for the first sample")
+	}
+
+	values, ok := rates.update("eth0", then.Add(500*time.Millisecond), 1500, 4000)
+	if !ok {
+		t.Fatal("expected rates")
+	}
+	if values[0] != 1000 || values[1] != 4000 {
+		t.Error("unexpected rates", values)
+	}
+
+	// counter reset
+	if _, ok := rates.update("eth0", then.Add(time.Second), 0, 0); ok {
+		t.Error("expected no rates after a counter reset")
+	}
+
+	rates.retain([]string{"wlan0"})
+	if _, ok := rates.update("eth0", then.Add(2*time.Second), 100, 100); ok {
+		t.Error("expected no rates for a forgotten key")
+	}
+}
@@ -0,0 +1,186 @@
+package telemd
+
+import (
+	"github.com/edgerun/telemd/internal/telem"
+	"log"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeviceSet is the set of devices of a kind (net or disk) that are monitored. It periodically rediscovers the
+// available devices, so that devices that are attached or removed at runtime (USB NICs, VPN tunnels, disks) are
+// picked up. Changes are reported as info event into the topic telem/<node>/events/info.
+type DeviceSet struct {
+	kind     string
+	list     func() ([]string, error)
+	include  []string
+	exclude  []string
+	interval time.Duration
+	events   telem.EventChannel
+
+	mutex     sync.RWMutex
+	devices   []string
+	listeners []func()
+}
+
+// NewDeviceSet creates a new DeviceSet that discovers the devices with the given list function. Devices are
+// filtered by the given glob patterns: if include patterns are given, a device has to match one of them, and it must
+// not match any exclude pattern.
+func NewDeviceSet(kind string, list func() ([]string, error), include []string, exclude []string,
+	interval time.Duration, events telem.EventChannel) *DeviceSet {
+	set := &DeviceSet{
+		kind:     kind,
+		list:     list,
+		include:  include,
+		exclude:  exclude,
+		interval: interval,
+		events:   events,
+	}
+	set.Refresh()
+	return set
+}
+
+// NewStaticDeviceSet creates a DeviceSet that contains the given devices and is never refreshed.
+func NewStaticDeviceSet(devices ...string) *DeviceSet {
+	return &DeviceSet{devices: devices}
+}
+
+// OnChange registers a function that is called after the set of devices has changed.
+func (set *DeviceSet) OnChange(listener func()) {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	set.listeners = append(set.listeners, listener)
+}
+
+// SetFilter replaces the include and exclude patterns of the set. They apply from the next discovery on.
+func (set *DeviceSet) SetFilter(include []string, exclude []string) {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	set.include = include
+	set.exclude = exclude
+}
+
+// Devices returns the currently monitored devices.
+func (set *DeviceSet) Devices() []string {
+	set.mutex.RLock()
+	defer set.mutex.RUnlock()
+	return set.devices
+}
+
+// Refresh rediscovers the devices and returns the devices that were added and removed since the last refresh.
+func (set *DeviceSet) Refresh() (added []string, removed []string) {
+	devices, err := set.list()
+	if err != nil {
+		log.Println("error discovering", set.kind, "devices", err)
+		return nil, nil
+	}
+
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	devices = filterDevices(devices, set.include, set.exclude)
+
+	if reflect.DeepEqual(devices, set.devices) {
+		return nil, nil
+	}
+
+	added = difference(devices, set.devices)
+	removed = difference(set.devices, devices)
+	set.devices = devices
+
+	return added, removed
+}
+
+// Run rediscovers the devices in the configured interval until the given channel is closed.
+func (set *DeviceSet) Run(done <-chan struct{}) {
+	if set.list == nil {
+		return // static
+	}
+
+	ticker := time.NewTicker(set.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			added, removed := set.Refresh()
+			if len(added) == 0 && len(removed) == 0 {
+				continue
+			}
+
+			log.Println(set.kind, "devices changed, added:", added, "removed:", removed)
+			set.notify()
+
+			event := telem.NewEvent("info", set.kind, map[string]string{
+				"value":   strings.Join(set.Devices(), " "),
+				"added":   strings.Join(added, " "),
+				"removed": strings.Join(removed, " "),
+			})
+
+			select {
+			case <-done:
+				return
+			case set.events.Channel() <- event:
+			}
+		}
+	}
+}
+
+func (set *DeviceSet) notify() {
+	set.mutex.RLock()
+	listeners := set.listeners
+	set.mutex.RUnlock()
+
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
+// filterDevices returns the devices that match any of the include patterns (or all devices if none are given), and
+// none of the exclude patterns.
+func filterDevices(devices []string, include []string, exclude []string) []string {
+	filtered := make([]string, 0, len(devices))
+
+	for _, device := range devices {
+		if len(include) > 0 && !matchAny(include, device) {
+			continue
+		}
+		if matchAny(exclude, device) {
+			continue
+		}
+		filtered = append(filtered, device)
+	}
+
+	return filtered
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, err := filepath.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// difference returns the elements of a that are not in b.
+func difference(a []string, b []string) []string {
+	var result []string
+
+outer:
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				continue outer
+			}
+		}
+		result = append(result, x)
+	}
+
+	return result
+}
@@ -0,0 +1,54 @@
+package telemd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFilterDevices(t *testing.T) {
+	devices := []string{"eth0", "wlan0", "docker0", "veth1a2b3c", "tun0", "wg0"}
+
+	filtered := filterDevices(devices, nil, []string{"veth*", "docker*"})
+	if !reflect.DeepEqual(filtered, []string{"eth0", "wlan0", "tun0", "wg0"}) {
+		t.Error("unexpected devices", filtered)
+	}
+
+	filtered = filterDevices(devices, []string{"eth*", "wlan*", "tun*"}, []string{"tun0"})
+	if !reflect.DeepEqual(filtered, []string{"eth0", "wlan0"}) {
+		t.Error("unexpected devices", filtered)
+	}
+}
+
+func TestDeviceSet_Refresh(t *testing.T) {
+	available := []string{"sda", "loop0"}
+	list := func() ([]string, error) {
+		return available, nil
+	}
+
+	set := NewDeviceSet("disk", list, nil, []string{"loop*"}, time.Second, nil)
+	if !reflect.DeepEqual(set.Devices(), []string{"sda"}) {
+		t.Error("unexpected devices", set.Devices())
+	}
+
+	if added, removed := set.Refresh(); len(added) != 0 || len(removed) != 0 {
+		t.Error("expected no changes, got", added, removed)
+	}
+
+	// usb disk attached
+	available = []string{"sda", "sdb", "loop0"}
+	added, removed := set.Refresh()
+	if !reflect.DeepEqual(added, []string{"sdb"}) || len(removed) != 0 {
+		t.Error("unexpected changes", added, removed)
+	}
+
+	// usb disk removed
+	available = []string{"sda", "loop0"}
+	added, removed = set.Refresh()
+	if len(added) != 0 || !reflect.DeepEqual(removed, []string{"sdb"}) {
+		t.Error("unexpected changes", added, removed)
+	}
+	if !reflect.DeepEqual(set.Devices(), []string{"sda"}) {
+		t.Error("unexpected devices", set.Devices())
+	}
+}
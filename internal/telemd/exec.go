@@ -0,0 +1,67 @@
+package telemd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/edgerun/telemd/internal/telem"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// ExecInstrument runs a shell command and reports the metrics the command writes to stdout. The output is parsed as
+// described in parseMetrics, and each metric is reported into telem/<node>/<metric>.
+type ExecInstrument struct {
+	Command string
+}
+
+func (instr ExecInstrument) Measure(ctx context.Context, channel telem.TelemetryChannel) error {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("sh", "-c", instr.Command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// run the command in its own process group, so that children of the shell are killed at the deadline as well
+	// and cannot keep stdout open
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan struct{})
+	defer close(exited)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-exited:
+		}
+	}()
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%v: %s", err, msg)
+		}
+		return err
+	}
+
+	samples, err := parseMetrics(&stdout)
+	reportMetrics(channel, samples)
+	return err
+}
+
+// reportMetrics puts the given samples into the channel, using the time of the sample if it has one.
+func reportMetrics(channel telem.TelemetryChannel, samples []metricSample) {
+	for _, sample := range samples {
+		t := telem.NewTelemetry(sample.Topic, sample.Value)
+		if !sample.Time.IsZero() {
+			t.Time = sample.Time
+		}
+		channel.Put(t)
+	}
+}
@@ -0,0 +1,66 @@
+package telemd
+
+import (
+	"context"
+	"github.com/edgerun/telemd/internal/telem"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecInstrument_Measure(t *testing.T) {
+	tc := telem.NewTelemetryChannel()
+	instrument := ExecInstrument{Command: `echo "queue_depth/orders 17"; echo 'up{job="a"} 1'`}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- instrument.Measure(context.Background(), tc)
+	}()
+
+	ch := tc.Channel()
+
+	t1 := <-ch
+	if t1.Topic != "queue_depth/orders" || t1.Value != 17 {
+		t.Error("Unexpected telemetry", t1)
+	}
+	t2 := <-ch
+	if t2.Topic != "up/a" || t2.Value != 1 {
+		t.Error("Unexpected telemetry", t2)
+	}
+
+	if err := <-errs; err != nil {
+		t.Error("Unexpected error", err)
+	}
+}
+
+func TestExecInstrument_MeasureFailingCommand(t *testing.T) {
+	tc := telem.NewTelemetryChannel()
+	instrument := ExecInstrument{Command: "echo broken >&2; exit 3"}
+
+	err := instrument.Measure(context.Background(), tc)
+
+	if err == nil {
+		t.Fatal("Expected an error for a failing command")
+	}
+	if !strings.Contains(err.Error(), "broken") {
+		t.Error("Expected the error to contain stderr, got", err)
+	}
+}
+
+func TestExecInstrument_MeasureTimeout(t *testing.T) {
+	tc := telem.NewTelemetryChannel()
+	instrument := ExecInstrument{Command: "sleep 5"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := instrument.Measure(ctx, tc)
+
+	if err != context.DeadlineExceeded {
+		t.Error("Expected deadline exceeded, got", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Error("Expected the command to be killed at the deadline")
+	}
+}
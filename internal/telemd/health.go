@@ -0,0 +1,94 @@
+package telemd
+
+import (
+	"encoding/json"
+	"github.com/edgerun/telemd/internal/telem"
+	"time"
+)
+
+func init() {
+	RegisterInstrument(InstrumentSpec{
+		Name:        "telemd_instruments",
+		Description: "health of all instruments as self-telemetry",
+		Period:      10 * time.Second,
+		New: func(env *InstrumentEnv) ContextInstrument {
+			return AdaptInstrument(NewInstrumentStatsInstrument(env.Stats))
+		},
+	})
+}
+
+// InstrumentStats describes the health of an instrument.
+type InstrumentStats struct {
+	// Runs is the number of completed measurements
+	Runs uint64
+	// Failures is the number of failed measurements, including timeouts
+	Failures uint64
+	// Timeouts is the number of measurements that exceeded their deadline
+	Timeouts uint64
+	// Skipped is the number of ticks that were skipped because the previous measurement was still running
+	Skipped uint64
+	Running bool
+	// Paused is true while the ticker of the instrument is paused, Period is its current period
+	Paused        bool
+	Period        time.Duration
+	LastRun       time.Time
+	LastDuration  time.Duration
+	LastError     string
+	LastErrorTime time.Time
+}
+
+// MarshalJSON encodes the stats with durations in seconds and times as UNIX timestamps, as used in telemetry values.
+func (stats InstrumentStats) MarshalJSON() ([]byte, error) {
+	doc := map[string]interface{}{
+		"runs":          stats.Runs,
+		"failures":      stats.Failures,
+		"timeouts":      stats.Timeouts,
+		"skipped":       stats.Skipped,
+		"running":       stats.Running,
+		"paused":        stats.Paused,
+		"period":        stats.Period.Seconds(),
+		"last_duration": stats.LastDuration.Seconds(),
+	}
+	if !stats.LastRun.IsZero() {
+		doc["last_run"] = float64(stats.LastRun.UnixNano()) / 1e9
+	}
+	if stats.LastError != "" {
+		doc["last_error"] = stats.LastError
+		doc["last_error_time"] = float64(stats.LastErrorTime.UnixNano()) / 1e9
+	}
+	return json.Marshal(doc)
+}
+
+// InstrumentStats returns the current stats of all running instruments by name.
+func (daemon *Daemon) InstrumentStats() map[string]InstrumentStats {
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+
+	stats := make(map[string]InstrumentStats, len(daemon.tickers))
+	for name, ticker := range daemon.tickers {
+		stats[name] = ticker.Stats()
+	}
+	return stats
+}
+
+// InstrumentStatsInstrument reports the stats of all instruments as self-telemetry into the topics
+// telemd/instruments/<instrument>/[runs|failures|timeouts|skipped|duration].
+type InstrumentStatsInstrument struct {
+	stats func() map[string]InstrumentStats
+}
+
+func NewInstrumentStatsInstrument(stats func() map[string]InstrumentStats) Instrument {
+	return &InstrumentStatsInstrument{stats}
+}
+
+func (instr *InstrumentStatsInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
+	for name, stats := range instr.stats() {
+		prefix := "telemd" + telem.TopicSeparator + "instruments" + telem.TopicSeparator + name + telem.TopicSeparator
+
+		channel.Put(telem.NewTelemetry(prefix+"runs", float64(stats.Runs)))
+		channel.Put(telem.NewTelemetry(prefix+"failures", float64(stats.Failures)))
+		channel.Put(telem.NewTelemetry(prefix+"timeouts", float64(stats.Timeouts)))
+		channel.Put(telem.NewTelemetry(prefix+"skipped", float64(stats.Skipped)))
+		channel.Put(telem.NewTelemetry(prefix+"duration", stats.LastDuration.Seconds()))
+	}
+}
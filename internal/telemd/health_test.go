@@ -0,0 +1,113 @@
+package telemd
+
+import (
+	"context"
+	"errors"
+	"github.com/edgerun/telemd/internal/telem"
+	"testing"
+	"time"
+)
+
+type blockingInstrument struct {
+	release chan struct{}
+}
+
+func (i blockingInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
+	<-i.release
+	channel.Put(telem.NewTelemetry("blocking", 1))
+}
+
+func TestTelemetryTicker_Timeout(t *testing.T) {
+	instrument := blockingInstrument{make(chan struct{})}
+	ticker := NewTelemetryTicker(AdaptInstrument(instrument), telem.NewTelemetryChannel(), 10*time.Millisecond, 20*time.Millisecond)
+
+	go ticker.Run()
+	defer ticker.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	stats := ticker.Stats()
+	if !stats.Running {
+		t.Error("expected the instrument to be running")
+	}
+	if stats.Skipped == 0 {
+		t.Error("expected ticks to be skipped while the instrument is blocked")
+	}
+	// the instrument cannot be interrupted, but the timeout is counted once the deadline has passed
+	if stats.Timeouts != 1 || stats.Failures != 1 {
+		t.Error("expected a timeout while the instrument is blocked, got", stats)
+	}
+
+	// nobody reads the telemetry channel, so the put must be dropped after the deadline
+	close(instrument.release)
+	time.Sleep(50 * time.Millisecond)
+
+	stats = ticker.Stats()
+	if stats.Runs == 0 || stats.Timeouts == 0 || stats.Failures != stats.Timeouts {
+		t.Error("unexpected stats", stats)
+	}
+	if stats.LastError == "" {
+		t.Error("expected a last error")
+	}
+}
+
+// goroutineInstrument reports from goroutines that outlive the measurement, like the kubernetes cgroup instruments.
+type goroutineInstrument struct {
+	values int
+}
+
+func (i goroutineInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
+	for n := 0; n < i.values; n++ {
+		go func() {
+			channel.Put(telem.NewTelemetry("goroutine", 1))
+		}()
+	}
+}
+
+func TestTelemetryTicker_ReportFromGoroutines(t *testing.T) {
+	tc := telem.NewTelemetryChannel()
+	ticker := NewTelemetryTicker(AdaptInstrument(goroutineInstrument{50}), tc, time.Hour, time.Second)
+
+	// the measurement returns before the goroutines have reported their values
+	ticker.(*telemetryTicker).measureOnce()
+
+	for n := 0; n < 50; n++ {
+		select {
+		case <-tc.Channel():
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected all values of the goroutines, got", n)
+		}
+	}
+}
+
+type failingInstrument struct {
+	err error
+}
+
+func (i failingInstrument) Measure(ctx context.Context, channel telem.TelemetryChannel) error {
+	if i.err == nil {
+		panic("no error given")
+	}
+	return i.err
+}
+
+func TestTelemetryTicker_Failures(t *testing.T) {
+	ticker := NewTelemetryTicker(failingInstrument{errors.New("no such file")}, telem.NewTelemetryChannel(), time.Second, time.Second).(*telemetryTicker)
+	ticker.measureOnce()
+
+	stats := ticker.Stats()
+	if stats.Runs != 1 || stats.Failures != 1 || stats.Timeouts != 0 {
+		t.Error("unexpected stats", stats)
+	}
+	if stats.LastError != "no such file" {
+		t.Error("unexpected last error", stats.LastError)
+	}
+
+	ticker = NewTelemetryTicker(failingInstrument{}, telem.NewTelemetryChannel(), time.Second, time.Second).(*telemetryTicker)
+	ticker.measureOnce()
+
+	stats = ticker.Stats()
+	if stats.Failures != 1 || stats.LastError != "instrument panicked: no error given" {
+		t.Error("expected the panic to be recorded as failure", stats)
+	}
+}
@@ -16,6 +16,8 @@ type NodeInfo struct {
 	Net      []string
 	Hostname string
 	NetSpeed string
+	Wifi     []WifiInfo
+	Groups   []string
 }
 
 func (info NodeInfo) Print() {
@@ -27,17 +29,19 @@ func (info NodeInfo) Print() {
 	fmt.Println("Net:      ", info.Net)
 	fmt.Println("Hostname: ", info.Hostname)
 	fmt.Println("netSpeed: ", info.NetSpeed)
+	fmt.Println("Wifi:     ", info.Wifi)
 }
 
-func SysInfo() NodeInfo {
+// SysInfo reads the info of the node, procMount is the mount point of the proc filesystem (see telemd_proc_mount).
+func SysInfo(procMount string) NodeInfo {
 	var info NodeInfo
 
-	ReadSysInfo(&info)
+	ReadSysInfo(&info, procMount)
 
 	return info
 }
 
-func ReadSysInfo(info *NodeInfo) {
+func ReadSysInfo(info *NodeInfo, procMount string) {
 	info.Arch = runtime.GOARCH
 	info.Cpus = runtime.NumCPU()
 
@@ -70,9 +74,17 @@ func ReadSysInfo(info *NodeInfo) {
 		log.Println("error reading hostname info", err)
 	}
 
-	if netSpeed, err := netSpeed(); err == nil {
+	if netSpeed, err := netSpeed(procMount, "/sys/class/net"); err == nil {
 		info.NetSpeed = netSpeed
 	} else {
 		log.Println("error reading network speed info", err)
 	}
+
+	if hasWirelessDevice() {
+		if wifi, err := wifiInfos(); err == nil {
+			info.Wifi = wifi
+		} else {
+			log.Println("error reading wifi info", err)
+		}
+	}
 }
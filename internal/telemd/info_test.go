@@ -1,9 +1,47 @@
 package telemd
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func TestReadSysInfo(t *testing.T) {
 	var info NodeInfo
-	ReadSysInfo(&info)
+	ReadSysInfo(&info, "/proc")
 	info.Print()
 }
+
+func TestNetSpeed(t *testing.T) {
+	root, err := ioutil.TempDir("", "telemd-netspeed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	proc, sysNet := filepath.Join(root, "proc"), filepath.Join(root, "net")
+	for _, dir := range []string{filepath.Join(proc, "net"), filepath.Join(sysNet, "eth0"), filepath.Join(sysNet, "wlan0")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(proc, "net", "route"), []byte(testIpv4Routes), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(sysNet, "eth0", "speed"), []byte("1000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(sysNet, "wlan0", "speed"), []byte("54\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// eth0 has the default route with the lowest metric in the given proc mount
+	speed, err := netSpeed(proc, sysNet)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if speed != "1000" {
+		t.Error("expected the speed of eth0, got", speed)
+	}
+}
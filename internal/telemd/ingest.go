@@ -0,0 +1,307 @@
+package telemd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/edgerun/telemd/internal/telem"
+	"log"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statsdShutdownFlush is how long the final flush on shutdown may wait for the telemetry channel before the remaining
+// metrics are dropped.
+const statsdShutdownFlush = 1 * time.Second
+
+// StatsdServer receives metrics of local applications in the StatsD protocol via UDP, aggregates them, and reports
+// them into the telemetry channel every flush interval. The dots in metric names are replaced by the topic separator,
+// i.e., `app.requests` is reported into telem/<node>/app/requests.
+//
+//   - counters (`name:1|c[|@rate]`) are reported as per-second rate over the flush interval
+//   - gauges (`name:42|g`, or `name:+1|g` to modify) report their last value every flush interval
+//   - timers and histograms (`name:320|ms`, `name:320|h`) report name/[count|mean|min|max|p90]
+//   - sets (`name:value|s`) report the number of unique values
+type StatsdServer struct {
+	addr     string
+	interval time.Duration
+	channel  telem.TelemetryChannel
+
+	mutex    sync.Mutex
+	counters map[string]float64
+	gauges   map[string]float64
+	timers   map[string][]float64
+	sets     map[string]map[string]struct{}
+}
+
+func NewStatsdServer(addr string, interval time.Duration, channel telem.TelemetryChannel) *StatsdServer {
+	return &StatsdServer{
+		addr:     addr,
+		interval: interval,
+		channel:  channel,
+		counters: make(map[string]float64),
+		gauges:   make(map[string]float64),
+		timers:   make(map[string][]float64),
+		sets:     make(map[string]map[string]struct{}),
+	}
+}
+
+func (s *StatsdServer) Run(done <-chan struct{}) {
+	conn, err := net.ListenPacket("udp", s.addr)
+	if err != nil {
+		log.Println("not receiving statsd metrics:", err)
+		return
+	}
+	log.Println("receiving statsd metrics on", conn.LocalAddr())
+
+	s.serve(conn, done)
+}
+
+func (s *StatsdServer) serve(conn net.PacketConn, done <-chan struct{}) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.receive(conn)
+	}()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			_ = conn.Close()
+			wg.Wait()
+			ctx, cancel := context.WithTimeout(context.Background(), statsdShutdownFlush)
+			s.flush(ctx.Done())
+			cancel()
+			return
+		case <-ticker.C:
+			s.flush(done)
+		}
+	}
+}
+
+func (s *StatsdServer) receive(conn net.PacketConn) {
+	buf := make([]byte, 65535)
+
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return // closed
+		}
+
+		// a packet may contain several metrics separated by newlines
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			if err := s.handle(line); err != nil {
+				log.Println("dropping statsd metric:", err)
+			}
+		}
+	}
+}
+
+// handle parses and aggregates a single statsd metric, e.g., `app.requests:1|c|@0.5`.
+func (s *StatsdServer) handle(line string) error {
+	parts := strings.Split(line, "|")
+	colon := strings.LastIndex(parts[0], ":")
+	if colon <= 0 || len(parts) < 2 {
+		return errors.New("expected name:value|type: " + line)
+	}
+	name := strings.Replace(parts[0][:colon], ".", telem.TopicSeparator, -1)
+	raw, kind := parts[0][colon+1:], parts[1]
+
+	if kind == "s" {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.sets[name] == nil {
+			s.sets[name] = make(map[string]struct{})
+		}
+		s.sets[name][raw] = struct{}{}
+		return nil
+	}
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return errors.New("invalid value: " + line)
+	}
+
+	rate := 1.0
+	for _, part := range parts[2:] {
+		// other extensions, e.g., dogstatsd tags (|#tag:value), are ignored
+		if strings.HasPrefix(part, "@") {
+			rate, err = strconv.ParseFloat(part[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return errors.New("invalid sample rate: " + line)
+			}
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch kind {
+	case "c":
+		s.counters[name] += value / rate
+	case "g":
+		if strings.HasPrefix(raw, "+") || strings.HasPrefix(raw, "-") {
+			s.gauges[name] += value
+		} else {
+			s.gauges[name] = value
+		}
+	case "ms", "h":
+		s.timers[name] = append(s.timers[name], value)
+	default:
+		return errors.New("unknown metric type " + kind + ": " + line)
+	}
+	return nil
+}
+
+// flush reports the aggregated metrics and resets counters, timers and sets. Values are dropped once done is closed.
+func (s *StatsdServer) flush(done <-chan struct{}) {
+	s.mutex.Lock()
+	counters, timers, sets := s.counters, s.timers, s.sets
+	s.counters = make(map[string]float64)
+	s.timers = make(map[string][]float64)
+	s.sets = make(map[string]map[string]struct{})
+
+	gauges := make(map[string]float64, len(s.gauges))
+	for name, value := range s.gauges {
+		gauges[name] = value
+	}
+	s.mutex.Unlock()
+
+	channel := newShutdownChannel(done, s.channel)
+	for name, value := range counters {
+		channel.Put(telem.NewTelemetry(name, value/s.interval.Seconds()))
+	}
+	for name, value := range gauges {
+		channel.Put(telem.NewTelemetry(name, value))
+	}
+	for name, values := range sets {
+		channel.Put(telem.NewTelemetry(name, float64(len(values))))
+	}
+	for name, values := range timers {
+		sort.Float64s(values)
+
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		// nearest rank
+		p90 := values[int(math.Ceil(0.9*float64(len(values))))-1]
+
+		prefix := name + telem.TopicSeparator
+		channel.Put(telem.NewTelemetry(prefix+"count", float64(len(values))))
+		channel.Put(telem.NewTelemetry(prefix+"mean", sum/float64(len(values))))
+		channel.Put(telem.NewTelemetry(prefix+"min", values[0]))
+		channel.Put(telem.NewTelemetry(prefix+"max", values[len(values)-1]))
+		channel.Put(telem.NewTelemetry(prefix+"p90", p90))
+	}
+}
+
+// LineServer receives metrics of local applications on a unix socket, and reports them immediately into the
+// telemetry channel. Each line is a metric in the format described in parseMetrics, e.g., `queue_depth/orders 17`.
+type LineServer struct {
+	path    string
+	channel telem.TelemetryChannel
+}
+
+func NewLineServer(path string, channel telem.TelemetryChannel) *LineServer {
+	return &LineServer{path: path, channel: channel}
+}
+
+func (s *LineServer) Run(done <-chan struct{}) {
+	listener, err := s.listen()
+	if err != nil {
+		log.Println("not receiving metrics on unix socket:", err)
+		return
+	}
+	log.Println("receiving metrics on", s.path)
+
+	s.serve(listener, done)
+}
+
+func (s *LineServer) listen() (net.Listener, error) {
+	// remove the socket of a previous run
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return net.Listen("unix", s.path)
+}
+
+func (s *LineServer) serve(listener net.Listener, done <-chan struct{}) {
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	conns := make(map[net.Conn]struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return // closed
+			}
+
+			mutex.Lock()
+			conns[conn] = struct{}{}
+			mutex.Unlock()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.receive(conn, done)
+
+				mutex.Lock()
+				delete(conns, conn)
+				mutex.Unlock()
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	<-done
+	_ = listener.Close()
+
+	mutex.Lock()
+	for conn := range conns {
+		_ = conn.Close()
+	}
+	mutex.Unlock()
+
+	wg.Wait()
+}
+
+func (s *LineServer) receive(conn net.Conn, done <-chan struct{}) {
+	channel := newShutdownChannel(done, s.channel)
+
+	scanner := bufio.NewScanner(conn)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		sample, err := parseMetricLine(line)
+		if err != nil {
+			log.Println("dropping metric:", err)
+			continue
+		}
+		if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+		reportMetrics(channel, []metricSample{sample})
+	}
+}
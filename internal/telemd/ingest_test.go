@@ -0,0 +1,207 @@
+package telemd
+
+import (
+	"github.com/edgerun/telemd/internal/telem"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// collectTelemetry runs f and returns the values it put into the channel by topic.
+func collectTelemetry(t *testing.T, tc telem.TelemetryChannel, f func()) map[string]float64 {
+	finished := make(chan struct{})
+	go func() {
+		f()
+		close(finished)
+	}()
+
+	values := make(map[string]float64)
+	for {
+		select {
+		case telemetry := <-tc.Channel():
+			values[telemetry.Topic] = telemetry.Value
+		case <-finished:
+			return values
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timeout while collecting telemetry")
+		}
+	}
+}
+
+func TestStatsdServer_Flush(t *testing.T) {
+	tc := telem.NewTelemetryChannel()
+	server := NewStatsdServer("", 10*time.Second, tc)
+
+	lines := []string{
+		"app.requests:10|c",
+		"app.requests:5|c|@0.5",
+		"app.queue:4|g",
+		"app.queue:+2|g",
+		"app.queue:-1|g",
+		"app.latency:10|ms",
+		"app.latency:30|ms",
+		"app.latency:20|ms|#env:test",
+		"app.users:alice|s",
+		"app.users:bob|s",
+		"app.users:alice|s",
+	}
+	for _, line := range lines {
+		if err := server.handle(line); err != nil {
+			t.Error("Unexpected error", err)
+		}
+	}
+
+	values := collectTelemetry(t, tc, func() { server.flush(nil) })
+
+	expected := map[string]float64{
+		"app/requests":      2, // (10 + 5/0.5) / 10s
+		"app/queue":         5,
+		"app/latency/count": 3,
+		"app/latency/mean":  20,
+		"app/latency/min":   10,
+		"app/latency/max":   30,
+		"app/latency/p90":   30,
+		"app/users":         2,
+	}
+	for topic, value := range expected {
+		if values[topic] != value {
+			t.Errorf("Expected %s to be %v, got %v", topic, value, values[topic])
+		}
+	}
+
+	// counters, timers and sets are reset, gauges keep their value
+	values = collectTelemetry(t, tc, func() { server.flush(nil) })
+
+	if len(values) != 1 || values["app/queue"] != 5 {
+		t.Error("Expected only the gauge after the second flush, got", values)
+	}
+}
+
+func TestStatsdServer_HandleMalformed(t *testing.T) {
+	server := NewStatsdServer("", 10*time.Second, telem.NewTelemetryChannel())
+
+	for _, line := range []string{"app.requests", "app.requests:1", "app.requests:x|c", "app.requests:1|x", "app.requests:1|c|@2"} {
+		if err := server.handle(line); err == nil {
+			t.Error("Expected an error for", line)
+		}
+	}
+}
+
+func TestStatsdServer_Serve(t *testing.T) {
+	tc := telem.NewTelemetryChannel()
+	server := NewStatsdServer("", time.Hour, tc)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen on udp:", err)
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		server.serve(conn, done)
+		close(stopped)
+	}()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	_, _ = client.Write([]byte("app.temperature:21.5|g\napp.humidity:40|g"))
+
+	select {
+	case telemetry := <-tc.Channel():
+		t.Error("Expected no telemetry before the flush, got", telemetry)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// stopping the server flushes the remaining metrics
+	values := collectTelemetry(t, tc, func() {
+		close(done)
+		<-stopped
+	})
+
+	if values["app/temperature"] != 21.5 || values["app/humidity"] != 40 {
+		t.Error("Unexpected values", values)
+	}
+}
+
+func TestStatsdServer_ServeWithoutReader(t *testing.T) {
+	server := NewStatsdServer("", 10*time.Millisecond, telem.NewTelemetryChannel())
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen on udp:", err)
+	}
+	if err := server.handle("app.queue:4|g"); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		server.serve(conn, done)
+		close(stopped)
+	}()
+
+	// nobody reads the telemetry channel, e.g., because redis is unavailable, so the flushes drop the gauge
+	time.Sleep(50 * time.Millisecond)
+	close(done)
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the server to stop")
+	}
+}
+
+func TestLineServer_Serve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telemd-ingest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tc := telem.NewTelemetryChannel()
+	server := NewLineServer(filepath.Join(dir, "telemd.sock"), tc)
+
+	listener, err := server.listen()
+	if err != nil {
+		t.Skip("cannot listen on unix socket:", err)
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		server.serve(listener, done)
+		close(stopped)
+	}()
+
+	conn, err := net.Dial("unix", filepath.Join(dir, "telemd.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = conn.Write([]byte("# comment\nqueue_depth/orders 17\nbroken\nup{job=\"a\"} 1\n"))
+
+	t1 := <-tc.Channel()
+	if t1.Topic != "queue_depth/orders" || t1.Value != 17 {
+		t.Error("Unexpected telemetry", t1)
+	}
+	t2 := <-tc.Channel()
+	if t2.Topic != "up/a" || t2.Value != 1 {
+		t.Error("Unexpected telemetry", t2)
+	}
+
+	// stopping the server closes open connections
+	close(done)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the server to stop")
+	}
+}
@@ -2,6 +2,8 @@ package telemd
 
 import (
 	"bufio"
+	"context"
+	"fmt"
 	"github.com/edgerun/telemd/internal/telem"
 	"log"
 	"os"
@@ -9,7 +11,6 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
@@ -19,65 +20,61 @@ type Instrument interface {
 	MeasureAndReport(telemetry telem.TelemetryChannel)
 }
 
-type InstrumentFactory interface {
-	NewCpuFrequencyInstrument() Instrument
-	NewCpuUtilInstrument() Instrument
-	NewLoadInstrument() Instrument
-	NewProcsInstrument() Instrument
-	NewRamInstrument() Instrument
-	NewNetworkDataRateInstrument([]string) Instrument
-	NewDiskDataRateInstrument([]string) Instrument
-	NewDockerCgroupCpuInstrument() Instrument
-	NewKubernetesCgroupCpuInstrument() Instrument
-	NewDockerCgroupBlkioInstrument() Instrument
-	NewDockerCgroupNetworkInstrument(string) Instrument
-	NewDockerCgroupMemoryInstrument() Instrument
-	NewKubernetesCgroupBlkioInstrument() Instrument
-	NewKubernetesCgroupMemoryInstrument() Instrument
-	NewKubernetesCgroupNetInstrument(string) Instrument
-	NewPsiCpuInstrument() Instrument
-	NewPsiMemoryInstrument() Instrument
-	NewPsiIoInstrument() Instrument
-	NewWifiTxBitrateInstrument(string) Instrument
-	NewWifiRxBitrateInstrument(string) Instrument
-	NewWifiSignalInstrument(string) Instrument
+// ContextInstrument is the successor of Instrument. Measure executes a measurement and puts the Telemetry into the
+// given TelemetryChannel. It should return once the context is done, and returns an error if the measurement failed.
+type ContextInstrument interface {
+	Measure(ctx context.Context, telemetry telem.TelemetryChannel) error
+}
+
+// instrumentAdapter runs an Instrument as ContextInstrument.
+type instrumentAdapter struct {
+	instrument Instrument
+}
+
+// AdaptInstrument returns a ContextInstrument that runs the given Instrument. As an Instrument cannot report errors,
+// the adapter only fails if the instrument exceeds the deadline of the context. The deadline cannot interrupt the
+// instrument, it only drops the values it reports afterwards.
+func AdaptInstrument(instrument Instrument) ContextInstrument {
+	return instrumentAdapter{instrument}
+}
+
+func (a instrumentAdapter) Measure(ctx context.Context, channel telem.TelemetryChannel) error {
+	a.instrument.MeasureAndReport(channel)
+	return ctx.Err()
 }
 
 type CpuInfoFrequencyInstrument struct{}
 type CpuScalingFrequencyInstrument struct{}
-type CpuUtilInstrument struct{}
+type CpuUtilInstrument struct {
+	previous []float64
+}
 type LoadInstrument struct{}
 type ProcsInstrument struct{}
 type RamInstrument struct{}
 type PsiCpuInstrument struct{}
 type PsiMemoryInstrument struct{}
 type PsiIoInstrument struct{}
-type WifiRxBitrateInstrument struct {
-	Device string
-}
-type WifiTxBitrateInstrument struct {
-	Device string
-}
-type WifiSignalInstrument struct {
-	Device string
-}
 type NetworkDataRateInstrument struct {
-	Devices []string
+	Devices *DeviceSet
+	rates   *counterRates
 }
 type DiskDataRateInstrument struct {
-	Devices []string
+	Devices *DeviceSet
+	rates   *counterRates
 }
 type DockerCgroupv1CpuInstrument struct{}
 type DockerCgroupv2CpuInstrument struct{}
 type DockerCgroupv1BlkioInstrument struct{}
 type DockerCgroupv2BlkioInstrument struct{}
 type DockerCgroupv1NetworkInstrument struct {
-	pids      map[string]string
-	procMount string
+	pids       map[string]string
+	procMount  string
+	containers *ContainerMetadataCache
 }
 type DockerCgroupv2NetworkInstrument struct {
-	pids      map[string]string
-	procMount string
+	pids       map[string]string
+	procMount  string
+	containers *ContainerMetadataCache
 }
 
 type DockerCgroupv1MemoryInstrument struct{}
@@ -98,24 +95,36 @@ type KubernetesCgroupv2NetworkInstrument struct {
 	procMount string
 }
 
-func (CpuUtilInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
-	then := readCpuUtil()
-	time.Sleep(500 * time.Millisecond)
-	now := readCpuUtil()
+func (instr *CpuUtilInstrument) Measure(ctx context.Context, channel telem.TelemetryChannel) error {
+	now, err := readCpuUtil()
+	if err != nil {
+		return err
+	}
+
+	then := instr.previous
+	instr.previous = now
+
+	if then == nil {
+		return nil // utilization is calculated between two ticks
+	}
+
+	busy := now[0] - then[0] + now[2] - then[2]
+	total := busy + now[3] - then[3]
+	if total <= 0 {
+		return nil
+	}
 
-	val := (now[0] - then[0] + now[2] - then[2]) * 100. / (now[0] - then[0] + now[2] - then[2] + now[3] - then[3])
+	val := busy * 100. / total
 	channel.Put(telem.NewTelemetry("cpu", val))
+	return nil
 }
 
-func (CpuInfoFrequencyInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
+func (CpuInfoFrequencyInstrument) Measure(ctx context.Context, channel telem.TelemetryChannel) error {
 	file, err := os.Open("/proc/cpuinfo")
-	check(err)
-	defer func() {
-		err = file.Close()
-		if err != nil {
-			log.Fatal(err)
-		}
-	}()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
 
@@ -133,34 +142,42 @@ func (CpuInfoFrequencyInstrument) MeasureAndReport(channel telem.TelemetryChanne
 				log.Println("could not parse value: '", strval, "' to float:", err)
 			}
 		}
-
-		if err := scanner.Err(); err != nil {
-			log.Fatal(err)
-		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
 	}
 
 	channel.Put(telem.NewTelemetry("freq", sum))
+	return nil
 }
 
 var cpuScalingFiles, _ = filepath.Glob("/sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_cur_freq")
 
-func (c CpuScalingFrequencyInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
+func (c CpuScalingFrequencyInstrument) Measure(ctx context.Context, channel telem.TelemetryChannel) error {
 	var sum int64
 
 	for _, match := range cpuScalingFiles {
 		value, err := readLineAndParseInt(match)
-		check(err)
+		if err != nil {
+			return err
+		}
 		sum += value
 	}
 
 	channel.Put(telem.NewTelemetry("freq", float64(sum)))
+	return nil
 }
 
-func (LoadInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
+func (LoadInstrument) Measure(ctx context.Context, channel telem.TelemetryChannel) error {
 	text, err := readFirstLine("/proc/loadavg")
-	check(err)
+	if err != nil {
+		return err
+	}
 
 	parts := strings.Split(text, " ")
+	if len(parts) < 2 {
+		return fmt.Errorf("unexpected loadavg format: %s", text)
+	}
 
 	l1 := parts[0]
 	l5 := parts[1]
@@ -178,120 +195,116 @@ func (LoadInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
 	//	channel.Put(NewTelemetry("load15", val))
 	//}
 
+	return nil
 }
 
-func (ProcsInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
+func (ProcsInstrument) Measure(ctx context.Context, channel telem.TelemetryChannel) error {
 	text, err := readFirstLine("/proc/loadavg")
-	check(err)
+	if err != nil {
+		return err
+	}
 
 	fields := strings.Split(text, " ")
+	if len(fields) < 4 {
+		return fmt.Errorf("unexpected loadavg format: %s", text)
+	}
 
 	procs := strings.Split(fields[3], "/")[0]
 
-	if val, err := strconv.ParseFloat(procs, 64); err == nil {
-		channel.Put(telem.NewTelemetry("procs", val))
+	val, err := strconv.ParseFloat(procs, 64)
+	if err != nil {
+		return err
 	}
+	channel.Put(telem.NewTelemetry("procs", val))
+	return nil
 }
 
 func (instr *NetworkDataRateInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
-	var wg sync.WaitGroup
-	wg.Add(len(instr.Devices))
-	defer wg.Wait()
+	if instr.rates == nil {
+		instr.rates = newCounterRates()
+	}
 
-	measureAndReport := func(device string) {
+	devices := instr.Devices.Devices()
+	instr.rates.retain(devices)
+
+	for _, device := range devices {
 		rxPath := "/sys/class/net/" + device + "/statistics/rx_bytes"
 		txPath := "/sys/class/net/" + device + "/statistics/tx_bytes"
 
-		rxThen, err := readLineAndParseInt(rxPath)
+		rx, err := readLineAndParseInt(rxPath)
 		if err != nil {
 			log.Println("error while reading path", rxPath, err)
-			return
+			continue
 		}
-		txThen, err := readLineAndParseInt(txPath)
+		tx, err := readLineAndParseInt(txPath)
 		if err != nil {
 			log.Println("error while reading path", txPath, err)
-			return
+			continue
 		}
-		time.Sleep(1 * time.Second)
 
-		rxNow, err := readLineAndParseInt(rxPath)
-		if err != nil {
-			log.Println("error while reading path", rxPath, err)
-			return
-		}
-		txNow, err := readLineAndParseInt(txPath)
-		if err != nil {
-			log.Println("error while reading path", txPath, err)
-			return
+		rates, ok := instr.rates.update(device, time.Now(), tx, rx)
+		if !ok {
+			continue
 		}
 
-		channel.Put(telem.NewTelemetry("tx"+telem.TopicSeparator+device, float64((txNow-txThen)/1000)))
-		channel.Put(telem.NewTelemetry("rx"+telem.TopicSeparator+device, float64((rxNow-rxThen)/1000)))
-		wg.Done()
-	}
-
-	for _, device := range instr.Devices {
-		go measureAndReport(device)
+		channel.Put(telem.NewTelemetry("tx"+telem.TopicSeparator+device, rates[0]/1000))
+		channel.Put(telem.NewTelemetry("rx"+telem.TopicSeparator+device, rates[1]/1000))
 	}
 }
 
 const sectorSize = 512
 
 func (instr *DiskDataRateInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
-	var wg sync.WaitGroup
-	wg.Add(len(instr.Devices))
-	defer wg.Wait()
+	if instr.rates == nil {
+		instr.rates = newCounterRates()
+	}
 
-	measureAndReport := func(device string) {
-		defer wg.Done()
+	devices := instr.Devices.Devices()
+	instr.rates.retain(devices)
 
-		statsThen, err := readBlockDeviceStats(device)
+	for _, device := range devices {
+		stats, err := readBlockDeviceStats(device)
 		if err != nil {
 			log.Println("error reading block device stats", device, err)
-			return
+			continue
 		}
 
-		time.Sleep(1 * time.Second)
-		statsNow, err := readBlockDeviceStats(device)
-		if err != nil {
-			log.Println("error reading block device stats", device, err)
-			return
+		rates, ok := instr.rates.update(device, time.Now(), stats[2]*sectorSize, stats[6]*sectorSize)
+		if !ok {
+			continue
 		}
 
-		rd := (statsNow[2] - statsThen[2]) * sectorSize
-		wr := (statsNow[6] - statsThen[6]) * sectorSize
-
-		channel.Put(telem.NewTelemetry("rd"+telem.TopicSeparator+device, float64(rd)/1000))
-		channel.Put(telem.NewTelemetry("wr"+telem.TopicSeparator+device, float64(wr)/1000))
-	}
-
-	for _, device := range instr.Devices {
-		go measureAndReport(device)
+		channel.Put(telem.NewTelemetry("rd"+telem.TopicSeparator+device, rates[0]/1000))
+		channel.Put(telem.NewTelemetry("wr"+telem.TopicSeparator+device, rates[1]/1000))
 	}
 }
 
-func (instr RamInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
-	meminfo := readMeminfo()
+func (instr RamInstrument) Measure(ctx context.Context, channel telem.TelemetryChannel) error {
+	meminfo, err := readMeminfo()
+	if err != nil {
+		return err
+	}
 
 	totalString, ok := meminfo["MemTotal"]
 	if !ok {
-		return
+		return fmt.Errorf("MemTotal missing in meminfo")
 	}
 	total, err := parseMeminfoString(totalString)
 	if err != nil {
-		log.Println("Error parsing MemTotal string", totalString, err)
+		return fmt.Errorf("error parsing MemTotal string %s: %v", totalString, err)
 	}
 
 	freeString, ok := meminfo["MemAvailable"]
 	if !ok {
-		return
+		return fmt.Errorf("MemAvailable missing in meminfo")
 	}
 	free, err := parseMeminfoString(freeString)
 	if err != nil {
-		log.Println("Error parsing MemFree string", freeString, err)
+		return fmt.Errorf("error parsing MemAvailable string %s: %v", freeString, err)
 	}
 
 	channel.Put(telem.NewTelemetry("ram", float64(total-free)))
+	return nil
 }
 
 func (PsiCpuInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
@@ -324,36 +337,6 @@ func (PsiIoInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
 	}
 }
 
-func (i WifiTxBitrateInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
-	bitrate, err := parseIw(i.Device, "tx bitrate", 3)
-	if err == nil {
-		value, err := strconv.ParseFloat(bitrate, 64)
-		if err == nil {
-			channel.Put(telem.NewTelemetry("tx_bitrate"+telem.TopicSeparator+i.Device, value))
-		}
-	}
-}
-
-func (i WifiRxBitrateInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
-	bitrate, err := parseIw(i.Device, "rx bitrate", 3)
-	if err == nil {
-		value, err := strconv.ParseFloat(bitrate, 64)
-		if err == nil {
-			channel.Put(telem.NewTelemetry("rx_bitrate"+telem.TopicSeparator+i.Device, value))
-		}
-	}
-}
-
-func (i WifiSignalInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
-	bitrate, err := parseIw(i.Device, "signal", 2)
-	if err == nil {
-		value, err := strconv.ParseFloat(bitrate, 64)
-		if err == nil {
-			channel.Put(telem.NewTelemetry("signal"+telem.TopicSeparator+i.Device, value))
-		}
-	}
-}
-
 func (DockerCgroupv1CpuInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
 	dirs, err := listFilterDir("/sys/fs/cgroup/cpuacct/docker", func(info os.FileInfo) bool {
 		return info.IsDir() && info.Name() != "." && info.Name() != ".."
@@ -543,7 +526,7 @@ func (c *DockerCgroupv1NetworkInstrument) MeasureAndReport(channel telem.Telemet
 
 		if !ok {
 			// refresh pids
-			pids, err := containerProcessIds(c.procMount)
+			pids, err := dockerContainerPids(c.procMount, c.containers)
 			if err != nil {
 				log.Println("unable to get container process ids", err)
 				continue
@@ -638,6 +621,16 @@ func (c KubernetesCgroupv1NetworkInstrument) MeasureAndReport(channel telem.Tele
 
 }
 
+// kubernetesContainerId returns the full id of the container with the given cgroup directory, which is either named
+// after the runtime (e.g., cri-containerd-<id>.scope with the systemd cgroup driver), or just the id (cgroupfs driver).
+func kubernetesContainerId(containerDir string) string {
+	name := filepath.Base(containerDir)
+	if _, id, ok := parseCgroupDirName(name); ok {
+		return id
+	}
+	return name
+}
+
 func (c KubernetesCgroupv2NetworkInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
 	burstableDirname := "/sys/fs/cgroup/kubepods.slice/kubepods-burstable.slice"
 	bestEffortDirname := "/sys/fs/cgroup/kubepods.slice/kubepods-besteffort.slice"
@@ -649,7 +642,8 @@ func (c KubernetesCgroupv2NetworkInstrument) MeasureAndReport(channel telem.Tele
 			for _, containerDir := range fetchKubernetesContainerDirs(kubepodDir) {
 				go func(containerDir string) {
 					containerId := r.FindString(containerDir)
-					pid, ok := c.pids[containerId]
+					fullContainerId := kubernetesContainerId(containerDir)
+					pid, ok := c.pids[fullContainerId]
 
 					if !ok {
 						// refresh pids
@@ -660,7 +654,7 @@ func (c KubernetesCgroupv2NetworkInstrument) MeasureAndReport(channel telem.Tele
 						}
 						c.pids = pids
 
-						pid, ok = c.pids[containerId]
+						pid, ok = c.pids[fullContainerId]
 						if !ok {
 							log.Println("could not get pid of container after refresh", containerId)
 							return
@@ -670,7 +664,7 @@ func (c KubernetesCgroupv2NetworkInstrument) MeasureAndReport(channel telem.Tele
 					rxValues, txValues, err := readTotalProcessNetworkStats(pid, c.procMount)
 					if err != nil {
 						if os.IsNotExist(err) {
-							delete(c.pids, containerId) // delete now and wait for next iteration to refresh
+							delete(c.pids, fullContainerId) // delete now and wait for next iteration to refresh
 						} else {
 							log.Println("error parsing network stats of pid", pid, err, err)
 						}
@@ -716,7 +710,7 @@ func (c *DockerCgroupv2NetworkInstrument) MeasureAndReport(channel telem.Telemet
 
 		if !ok {
 			// refresh pids
-			pids, err := containerProcessIds(c.procMount)
+			pids, err := dockerContainerPids(c.procMount, c.containers)
 			if err != nil {
 				log.Println("unable to get container process ids", err)
 				continue
@@ -1032,59 +1026,128 @@ func (k KubernetesCgroupv2MemoryInstrument) MeasureAndReport(ch telem.TelemetryC
 	}
 }
 
-type defaultInstrumentFactory struct {
-}
-
-func (d defaultInstrumentFactory) NewCpuFrequencyInstrument() Instrument {
-	return CpuScalingFrequencyInstrument{}
-}
-
-func (d defaultInstrumentFactory) NewCpuUtilInstrument() Instrument {
-	return CpuUtilInstrument{}
-}
-
-func (d defaultInstrumentFactory) NewLoadInstrument() Instrument {
-	return LoadInstrument{}
-}
-
-func (d defaultInstrumentFactory) NewProcsInstrument() Instrument {
-	return ProcsInstrument{}
-}
-
-func (d defaultInstrumentFactory) NewRamInstrument() Instrument {
-	return RamInstrument{}
-}
-
-func (d defaultInstrumentFactory) NewNetworkDataRateInstrument(devices []string) Instrument {
-	return &NetworkDataRateInstrument{devices}
-}
-
-func (d defaultInstrumentFactory) NewDiskDataRateInstrument(devices []string) Instrument {
-	return &DiskDataRateInstrument{devices}
-}
-
-func (d defaultInstrumentFactory) NewWifiTxBitrateInstrument(device string) Instrument {
-	return &WifiTxBitrateInstrument{device}
-}
-
-func (d defaultInstrumentFactory) NewWifiRxBitrateInstrument(device string) Instrument {
-	return &WifiRxBitrateInstrument{device}
-}
-
-func (d defaultInstrumentFactory) NewWifiSignalInstrument(device string) Instrument {
-	return &WifiSignalInstrument{device}
-}
+func init() {
+	RegisterInstrument(InstrumentSpec{
+		Name:        "cpu",
+		Description: "CPU utilization in % since the previous measurement",
+		Period:      500 * time.Millisecond,
+		New: func(env *InstrumentEnv) ContextInstrument {
+			return &CpuUtilInstrument{}
+		},
+	})
+	RegisterInstrument(InstrumentSpec{
+		Name:        "freq",
+		Description: "sum of the clock frequencies of the main CPUs",
+		Period:      500 * time.Millisecond,
+		Available: func(env *InstrumentEnv) bool {
+			// only x86 reports the current frequency in /proc/cpuinfo
+			return len(cpuScalingFiles) > 0 || isX86(env.Arch)
+		},
+		New: func(env *InstrumentEnv) ContextInstrument {
+			if len(cpuScalingFiles) == 0 {
+				return CpuInfoFrequencyInstrument{}
+			}
+			return CpuScalingFrequencyInstrument{}
+		},
+	})
+	RegisterInstrument(InstrumentSpec{
+		Name:        "load",
+		Description: "system load average of the last 1 and 5 minutes",
+		Period:      5 * time.Second,
+		New: func(env *InstrumentEnv) ContextInstrument {
+			return LoadInstrument{}
+		},
+	})
+	RegisterInstrument(InstrumentSpec{
+		Name:        "procs",
+		Description: "number of processes running at the current time",
+		Period:      500 * time.Millisecond,
+		New: func(env *InstrumentEnv) ContextInstrument {
+			return ProcsInstrument{}
+		},
+	})
+	RegisterInstrument(InstrumentSpec{
+		Name:        "ram",
+		Description: "RAM currently used in kilobytes",
+		Period:      1 * time.Second,
+		New: func(env *InstrumentEnv) ContextInstrument {
+			return RamInstrument{}
+		},
+	})
+	RegisterInstrument(InstrumentSpec{
+		Name:        "net",
+		Description: "network I/O rate in kilobytes/second per device",
+		Period:      500 * time.Millisecond,
+		Options: []InstrumentOption{
+			{"telemd_net_devices", "all", "glob patterns of the monitored devices"},
+			{"telemd_net_devices_exclude", "", "glob patterns of devices that are not monitored"},
+		},
+		New: func(env *InstrumentEnv) ContextInstrument {
+			return AdaptInstrument(&NetworkDataRateInstrument{Devices: env.NetDevices})
+		},
+	})
+	RegisterInstrument(InstrumentSpec{
+		Name:        "disk",
+		Description: "disk I/O rate in kilobytes/second per device",
+		Period:      500 * time.Millisecond,
+		Options: []InstrumentOption{
+			{"telemd_disk_devices", "all", "glob patterns of the monitored devices"},
+			{"telemd_disk_devices_exclude", "", "glob patterns of devices that are not monitored"},
+		},
+		New: func(env *InstrumentEnv) ContextInstrument {
+			return AdaptInstrument(&DiskDataRateInstrument{Devices: env.DiskDevices})
+		},
+	})
 
-func (d defaultInstrumentFactory) NewPsiCpuInstrument() Instrument {
-	return PsiCpuInstrument{}
-}
+	psi := map[string]Instrument{
+		"cpu":    PsiCpuInstrument{},
+		"memory": PsiMemoryInstrument{},
+		"io":     PsiIoInstrument{},
+	}
+	for resource, instrument := range psi {
+		resource, instrument := resource, instrument
+		RegisterInstrument(InstrumentSpec{
+			Name:        "psi_" + resource,
+			Description: "host's " + resource + " pressure stall information",
+			Period:      500 * time.Millisecond,
+			Available: func(env *InstrumentEnv) bool {
+				return fileDirExists("/proc/pressure/" + resource)
+			},
+			New: func(env *InstrumentEnv) ContextInstrument {
+				return AdaptInstrument(instrument)
+			},
+		})
+	}
 
-func (d defaultInstrumentFactory) NewPsiMemoryInstrument() Instrument {
-	return PsiMemoryInstrument{}
+	cgroupInstruments := []struct {
+		name        string
+		description string
+		new         func(env *InstrumentEnv) Instrument
+	}{
+		{"docker_cgrp_cpu", "cpu usage time of docker containers", newDockerCgroupCpuInstrument},
+		{"docker_cgrp_blkio", "block io usage in bytes of docker containers", newDockerCgroupBlkioInstrument},
+		{"docker_cgrp_net", "network io usage in bytes of docker containers", newDockerCgroupNetworkInstrument},
+		{"docker_cgrp_memory", "memory usage in bytes of docker containers", newDockerCgroupMemoryInstrument},
+		{"kubernetes_cgrp_cpu", "cpu usage time of Kubernetes Pod containers", newKubernetesCgroupCpuInstrument},
+		{"kubernetes_cgrp_blkio", "block io usage in bytes of Kubernetes Pod containers", newKubernetesCgroupBlkioInstrument},
+		{"kubernetes_cgrp_memory", "memory usage in bytes of Kubernetes Pod containers", newKubernetesCgroupMemoryInstrument},
+		{"kubernetes_cgrp_net", "network io usage in bytes of Kubernetes Pod containers", newKubernetesCgroupNetInstrument},
+	}
+	for _, instrument := range cgroupInstruments {
+		instrument := instrument
+		RegisterInstrument(InstrumentSpec{
+			Name:        instrument.name,
+			Description: instrument.description,
+			Period:      1 * time.Second,
+			New: func(env *InstrumentEnv) ContextInstrument {
+				return AdaptInstrument(instrument.new(env))
+			},
+		})
+	}
 }
 
-func (d defaultInstrumentFactory) NewPsiIoInstrument() Instrument {
-	return PsiIoInstrument{}
+func isX86(arch string) bool {
+	return arch == "amd64" || arch == "386"
 }
 
 func checkCgroup() string {
@@ -1095,85 +1158,78 @@ func checkCgroup() string {
 	}
 }
 
-func (d defaultInstrumentFactory) NewDockerCgroupCpuInstrument() Instrument {
-	cgroup := checkCgroup()
-	if cgroup == "v1" {
+func newDockerCgroupCpuInstrument(env *InstrumentEnv) Instrument {
+	if env.Cgroup == "v1" {
 		return DockerCgroupv1CpuInstrument{}
 	} else {
 		return DockerCgroupv2CpuInstrument{}
 	}
-
 }
 
-func (d defaultInstrumentFactory) NewKubernetesCgroupCpuInstrument() Instrument {
-	cgroup := checkCgroup()
-	if cgroup == "v1" {
+func newKubernetesCgroupCpuInstrument(env *InstrumentEnv) Instrument {
+	if env.Cgroup == "v1" {
 		return KubernetesCgroupv1CpuInstrument{}
 	} else {
 		return KubernetesCgroupv2CpuInstrument{}
 	}
-
 }
 
-func (d defaultInstrumentFactory) NewDockerCgroupBlkioInstrument() Instrument {
-	cgroup := checkCgroup()
-	if cgroup == "v1" {
+func newDockerCgroupBlkioInstrument(env *InstrumentEnv) Instrument {
+	if env.Cgroup == "v1" {
 		return DockerCgroupv1BlkioInstrument{}
 	} else {
 		return DockerCgroupv2BlkioInstrument{}
 	}
 }
 
-func (d defaultInstrumentFactory) NewDockerCgroupNetworkInstrument(procMount string) Instrument {
-	pidMap, err := containerProcessIds(procMount)
+func newDockerCgroupNetworkInstrument(env *InstrumentEnv) Instrument {
+	procMount := env.Config.Mounts.Proc
+	pidMap, err := dockerContainerPids(procMount, env.Containers)
 
 	if err != nil {
 		log.Println("unable to get process ids of containers", err)
 	}
-	cgroup := checkCgroup()
 
-	if cgroup == "v1" {
+	if env.Cgroup == "v1" {
 		return &DockerCgroupv1NetworkInstrument{
-			pids:      pidMap,
-			procMount: procMount,
+			pids:       pidMap,
+			procMount:  procMount,
+			containers: env.Containers,
 		}
 	} else {
 		return &DockerCgroupv2NetworkInstrument{
-			pids:      pidMap,
-			procMount: procMount,
+			pids:       pidMap,
+			procMount:  procMount,
+			containers: env.Containers,
 		}
 	}
-
 }
 
-func (d defaultInstrumentFactory) NewKubernetesCgroupBlkioInstrument() Instrument {
-	cgroup := checkCgroup()
-	if cgroup == "v1" {
+func newKubernetesCgroupBlkioInstrument(env *InstrumentEnv) Instrument {
+	if env.Cgroup == "v1" {
 		return KubernetesCgroupv1BlkioInstrument{}
 	} else {
 		return KubernetesCgroupv2BlkioInstrument{}
 	}
-
 }
 
-func (d defaultInstrumentFactory) NewKubernetesCgroupMemoryInstrument() Instrument {
-	cgroup := checkCgroup()
-	if cgroup == "v1" {
+func newKubernetesCgroupMemoryInstrument(env *InstrumentEnv) Instrument {
+	if env.Cgroup == "v1" {
 		return KuberenetesCgroupv1MemoryInstrument{}
 	} else {
 		return KubernetesCgroupv2MemoryInstrument{}
 	}
 }
-func (d defaultInstrumentFactory) NewKubernetesCgroupNetInstrument(procMount string) Instrument {
+
+func newKubernetesCgroupNetInstrument(env *InstrumentEnv) Instrument {
+	procMount := env.Config.Mounts.Proc
 	pidMap, err := containerProcessIds(procMount)
 
 	if err != nil {
 		log.Println("unable to get process ids of containers", err)
 	}
 
-	cgroup := checkCgroup()
-
-	if cgroup == "v1" {
+	if env.Cgroup == "v1" {
 		return KubernetesCgroupv1NetworkInstrument{
 			pids:      pidMap,
 			procMount: procMount,
@@ -1186,39 +1242,10 @@ func (d defaultInstrumentFactory) NewKubernetesCgroupNetInstrument(procMount str
 	}
 }
 
-func (d defaultInstrumentFactory) NewDockerCgroupMemoryInstrument() Instrument {
-	cgroup := checkCgroup()
-	if cgroup == "v1" {
+func newDockerCgroupMemoryInstrument(env *InstrumentEnv) Instrument {
+	if env.Cgroup == "v1" {
 		return DockerCgroupv1MemoryInstrument{}
 	} else {
 		return DockerCgroupv2MemoryInstrument{}
 	}
 }
-
-type armInstrumentFactory struct {
-	defaultInstrumentFactory
-}
-
-type x86InstrumentFactory struct {
-	defaultInstrumentFactory
-}
-
-func NewInstrumentFactory(arch string) InstrumentFactory {
-	switch arch {
-	case "amd64":
-		return x86InstrumentFactory{}
-	case "arm":
-	case "arm64":
-		return armInstrumentFactory{}
-	default:
-		log.Printf("Unknown arch %s, returning default factory", arch)
-	}
-
-	return defaultInstrumentFactory{}
-}
-
-func check(err error) {
-	if err != nil {
-		panic(err)
-	}
-}
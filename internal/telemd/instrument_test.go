@@ -1,9 +1,11 @@
 package telemd
 
 import (
+	"context"
 	"github.com/edgerun/telemd/internal/telem"
 	"log"
 	"testing"
+	"time"
 )
 
 // TODO: proper tests and use timeouts for channel reads
@@ -24,7 +26,11 @@ func TestReadBlockDeviceStats(t *testing.T) {
 
 func TestDiskDataRateInstrument_MeasureAndReport(t *testing.T) {
 	tc := telem.NewTelemetryChannel()
-	instrument := DiskDataRateInstrument{[]string{"loop0"}}
+	instrument := DiskDataRateInstrument{Devices: NewStaticDeviceSet("loop0")}
+
+	// the first measurement only records the initial sample
+	instrument.MeasureAndReport(tc)
+	time.Sleep(100 * time.Millisecond)
 
 	go instrument.MeasureAndReport(tc)
 	ch := tc.Channel()
@@ -42,11 +48,29 @@ func TestDiskDataRateInstrument_MeasureAndReport(t *testing.T) {
 	tc.Close()
 }
 
-func TestRamInstrument_MeasureAndReport(t *testing.T) {
+func TestCpuUtilInstrument_Measure(t *testing.T) {
+	var instrument CpuUtilInstrument
+	tc := telem.NewTelemetryChannel()
+
+	// the first measurement only records the initial sample
+	if err := instrument.Measure(context.Background(), tc); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	go instrument.Measure(context.Background(), tc)
+
+	t1 := <-tc.Channel()
+	if t1.Value < 0 || t1.Value > 100 {
+		t.Error("Expected utilization between 0 and 100, was", t1.Value)
+	}
+}
+
+func TestRamInstrument_Measure(t *testing.T) {
 	var instrument RamInstrument
 	tc := telem.NewTelemetryChannel()
 
-	go instrument.MeasureAndReport(tc)
+	go instrument.Measure(context.Background(), tc)
 
 	t1 := <-tc.Channel()
 	if t1.Value <= 0 {
@@ -55,11 +79,11 @@ func TestRamInstrument_MeasureAndReport(t *testing.T) {
 	log.Printf("%.4f\n", t1.Value)
 }
 
-func TestLoadInstrument_MeasureAndReport(t *testing.T) {
+func TestLoadInstrument_Measure(t *testing.T) {
 	var instrument LoadInstrument
 	tc := telem.NewTelemetryChannel()
 
-	go instrument.MeasureAndReport(tc)
+	go instrument.Measure(context.Background(), tc)
 
 	t0 := <-tc.Channel()
 	if t0.Topic != "load1" {
@@ -75,11 +99,11 @@ func TestLoadInstrument_MeasureAndReport(t *testing.T) {
 
 }
 
-func TestProcsInstrument_MeasureAndReport(t *testing.T) {
+func TestProcsInstrument_Measure(t *testing.T) {
 	var instrument ProcsInstrument
 	tc := telem.NewTelemetryChannel()
 
-	go instrument.MeasureAndReport(tc)
+	go instrument.Measure(context.Background(), tc)
 
 	t0 := <-tc.Channel()
 
@@ -0,0 +1,241 @@
+package telemd
+
+import (
+	"github.com/edgerun/telemd/internal/docker"
+	"github.com/edgerun/telemd/internal/telem"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ContainerStarted   = "started"
+	ContainerStopped   = "stopped"
+	ContainerOomKilled = "oom-killed"
+	ContainerRestarted = "restarted"
+)
+
+// containerState is what the ContainerLifecycleMonitor remembers about a container between two scans.
+type containerState struct {
+	cgroup   containerCgroup
+	info     os.FileInfo
+	oomKills int64
+}
+
+// ContainerLifecycleMonitor scans the cgroup hierarchy for containers of all known runtimes and reports the
+// differences between two scans as container events (started, stopped, oom-killed, restarted) into the topic
+// telem/<node>/events/container.
+type ContainerLifecycleMonitor struct {
+	root       string
+	interval   time.Duration
+	events     telem.EventChannel
+	containers *ContainerMetadataCache
+	pods       *PodMetadataResolver
+
+	states    map[string]containerState // by runtime/id
+	oomEvents chan telem.Event
+	oomKills  map[string]time.Time // by docker container id, the last reported oom kill
+}
+
+// NewContainerLifecycleMonitor creates a new ContainerLifecycleMonitor. The container metadata cache and pod resolver
+// are optional and used to add names to the reported events. If the metadata cache is given, OOM kills reported by the
+// docker daemon are also forwarded, as they typically remove the container's cgroup before the next scan.
+func NewContainerLifecycleMonitor(events telem.EventChannel, interval time.Duration, cgroupVersion string,
+	containers *ContainerMetadataCache, pods *PodMetadataResolver) *ContainerLifecycleMonitor {
+	root := "/sys/fs/cgroup"
+	if cgroupVersion == "v1" {
+		// the memory controller also provides the oom kill counter
+		root = "/sys/fs/cgroup/memory"
+	}
+
+	monitor := &ContainerLifecycleMonitor{
+		root:       root,
+		interval:   interval,
+		events:     events,
+		containers: containers,
+		pods:       pods,
+		oomEvents:  make(chan telem.Event, 16),
+		oomKills:   make(map[string]time.Time),
+	}
+
+	if containers != nil {
+		containers.OnEvent(monitor.handleDockerEvent)
+	}
+
+	return monitor
+}
+
+func (monitor *ContainerLifecycleMonitor) handleDockerEvent(event docker.Event) {
+	if event.Action != "oom" {
+		return
+	}
+
+	state := containerState{cgroup: containerCgroup{Runtime: dockerRuntime, Id: event.Id}}
+
+	// don't block the docker event stream, the event is forwarded by Run
+	select {
+	case monitor.oomEvents <- monitor.newEvent(ContainerOomKilled, state):
+	default:
+		log.Println("dropping container event, event queue is full")
+	}
+}
+
+// Run scans for container changes in the configured interval until the given channel is closed.
+func (monitor *ContainerLifecycleMonitor) Run(done <-chan struct{}) {
+	ticker := time.NewTicker(monitor.interval)
+	defer ticker.Stop()
+
+	monitor.scan() // initialize state
+
+	for {
+		select {
+		case <-done:
+			return
+		case event := <-monitor.oomEvents:
+			if !monitor.reportOomKill(event.Attributes["container_id"], time.Now()) {
+				continue
+			}
+			if !monitor.put(done, event) {
+				return
+			}
+		case <-ticker.C:
+			for _, event := range monitor.scan() {
+				if !monitor.put(done, event) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// put reports the event, and returns false if the given channel was closed before the event could be reported.
+func (monitor *ContainerLifecycleMonitor) put(done <-chan struct{}, event telem.Event) bool {
+	select {
+	case <-done:
+		return false
+	case monitor.events.Channel() <- event:
+		return true
+	}
+}
+
+// scan reads the current containers and returns the events that describe the changes since the last scan. The first
+// scan only initializes the state and returns no events.
+func (monitor *ContainerLifecycleMonitor) scan() []telem.Event {
+	states := make(map[string]containerState)
+
+	for _, cgroup := range findContainerCgroups(monitor.root) {
+		info, err := os.Stat(cgroup.Dir)
+		if err != nil {
+			continue // removed in the meantime
+		}
+		states[cgroup.Runtime.Name+"/"+cgroup.Id] = containerState{
+			cgroup:   cgroup,
+			info:     info,
+			oomKills: readCgroupOomKills(cgroup.Dir),
+		}
+	}
+
+	previous := monitor.states
+	monitor.states = states
+
+	if previous == nil {
+		return nil
+	}
+
+	var events []telem.Event
+
+	for key, state := range states {
+		last, ok := previous[key]
+
+		switch {
+		case !ok:
+			events = append(events, monitor.newEvent(ContainerStarted, state))
+		case !os.SameFile(last.info, state.info):
+			// the cgroup was re-created since the last scan
+			events = append(events, monitor.newEvent(ContainerRestarted, state))
+		case state.oomKills > last.oomKills:
+			if state.cgroup.Runtime != dockerRuntime || monitor.reportOomKill(state.cgroup.Id, time.Now()) {
+				events = append(events, monitor.newEvent(ContainerOomKilled, state))
+			}
+		}
+	}
+
+	for key, state := range previous {
+		if _, ok := states[key]; !ok {
+			events = append(events, monitor.newEvent(ContainerStopped, state))
+		}
+	}
+
+	return events
+}
+
+// reportOomKill returns whether an oom kill of the docker container should be reported. Both the docker event and the
+// oom kill counter of the cgroup report it, so the second report within two scan intervals is suppressed.
+func (monitor *ContainerLifecycleMonitor) reportOomKill(id string, now time.Time) bool {
+	window := 2 * monitor.interval
+
+	for container, reported := range monitor.oomKills {
+		if now.Sub(reported) > window {
+			delete(monitor.oomKills, container)
+		}
+	}
+
+	if _, ok := monitor.oomKills[id]; ok {
+		return false
+	}
+	monitor.oomKills[id] = now
+	return true
+}
+
+func (monitor *ContainerLifecycleMonitor) newEvent(eventType string, state containerState) telem.Event {
+	runtime := state.cgroup.Runtime.Name
+	if runtime == "" {
+		runtime = "kubernetes"
+	}
+
+	attributes := map[string]string{
+		"runtime":      runtime,
+		"container_id": state.cgroup.Id,
+	}
+
+	if monitor.containers != nil {
+		if container, ok := monitor.containers.Lookup(state.cgroup.Id); ok {
+			attributes["name"] = container.Name
+			attributes["image"] = container.Image
+		}
+	}
+
+	if monitor.pods != nil {
+		if container, ok := monitor.pods.LookupContainer(state.cgroup.Id); ok {
+			attributes["name"] = container.Container
+			attributes["pod"] = container.Pod
+			attributes["namespace"] = container.Namespace
+		}
+	}
+
+	return telem.NewEvent("container", eventType, attributes)
+}
+
+// readCgroupOomKills reads the number of processes of a cgroup that were killed by the OOM killer, either from the
+// memory.events (cgroup v2) or the memory.oom_control file (cgroup v1). Returns 0 if the value is not available.
+func readCgroupOomKills(dir string) int64 {
+	var value int64
+
+	visitor := func(line string) bool {
+		if strings.HasPrefix(line, "oom_kill ") {
+			value, _ = strconv.ParseInt(strings.TrimPrefix(line, "oom_kill "), 10, 64)
+			return false
+		}
+		return true
+	}
+
+	if err := visitLines(dir+"/memory.events", visitor); err == nil {
+		return value
+	}
+	if err := visitLines(dir+"/memory.oom_control", visitor); err != nil && !os.IsNotExist(err) {
+		log.Println("error reading oom kills of cgroup", dir, err)
+	}
+	return value
+}
@@ -0,0 +1,112 @@
+package telemd
+
+import (
+	"github.com/edgerun/telemd/internal/telem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestContainerLifecycleMonitor_scan(t *testing.T) {
+	root, err := ioutil.TempDir("", "telemd-cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	slice := filepath.Join(root, "system.slice")
+	stopped := filepath.Join(slice, "docker-"+testId1+".scope")
+	restarted := filepath.Join(slice, "cri-containerd-"+testId2+".scope")
+	started := filepath.Join(slice, "crio-"+testId1+".scope")
+
+	for _, dir := range []string{stopped, restarted} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeOomKills(t, restarted, 0)
+
+	monitor := NewContainerLifecycleMonitor(telem.NewEventChannel(), time.Second, "v2", nil, nil)
+	monitor.root = root
+
+	if events := monitor.scan(); len(events) != 0 {
+		t.Fatal("expected no events on the first scan, got", events)
+	}
+
+	writeOomKills(t, restarted, 1)
+	if events := monitor.scan(); len(events) != 1 || events[0].Type != ContainerOomKilled {
+		t.Fatal("expected an oom-killed event, got", events)
+	}
+
+	_ = os.RemoveAll(stopped)
+	_ = os.RemoveAll(restarted)
+	for _, dir := range []string{restarted, started} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	types := make(map[string]string)
+	for _, event := range monitor.scan() {
+		if event.Kind != "container" {
+			t.Error("unexpected event kind", event.Kind)
+		}
+		types[event.Attributes["runtime"]] = event.Type
+	}
+
+	expected := map[string]string{
+		"docker":     ContainerStopped,
+		"containerd": ContainerRestarted,
+		"crio":       ContainerStarted,
+	}
+	if len(types) != len(expected) {
+		t.Error("unexpected events", types)
+	}
+	for runtime, eventType := range expected {
+		if types[runtime] != eventType {
+			t.Errorf("expected %s event for %s container, got %q", eventType, runtime, types[runtime])
+		}
+	}
+}
+
+func TestContainerLifecycleMonitor_DockerOomKill(t *testing.T) {
+	root, err := ioutil.TempDir("", "telemd-cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	dir := filepath.Join(root, "system.slice", "docker-"+testId1+".scope")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeOomKills(t, dir, 0)
+
+	monitor := NewContainerLifecycleMonitor(telem.NewEventChannel(), time.Second, "v2", nil, nil)
+	monitor.root = root
+	monitor.scan()
+
+	// the docker event is reported first, the oom kill counter of the same kill must not be reported again
+	if !monitor.reportOomKill(testId1, time.Now()) {
+		t.Error("expected the first oom kill to be reported")
+	}
+	writeOomKills(t, dir, 1)
+	if events := monitor.scan(); len(events) != 0 {
+		t.Error("expected the oom kill to be reported once, got", events)
+	}
+
+	// a later oom kill is reported again
+	if !monitor.reportOomKill(testId1, time.Now().Add(time.Minute)) {
+		t.Error("expected a later oom kill to be reported")
+	}
+}
+
+func writeOomKills(t *testing.T, dir string, kills int) {
+	content := "low 0\nhigh 0\nmax 0\noom 0\noom_kill " + strconv.Itoa(kills) + "\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "memory.events"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,144 @@
+package telemd
+
+import (
+	"context"
+	"github.com/edgerun/telemd/internal/kubelet"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PodContainer describes a container of a Kubernetes pod running on this node.
+type PodContainer struct {
+	ContainerId string            `json:"container_id"`
+	Container   string            `json:"container"`
+	PodUid      string            `json:"pod_uid"`
+	Pod         string            `json:"pod"`
+	Namespace   string            `json:"namespace"`
+	OwnerKind   string            `json:"owner_kind,omitempty"`
+	OwnerName   string            `json:"owner_name,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// PodMetadataResolver periodically queries the kubelet for the pods of this node, and maps pod UIDs and container ids
+// (as found in the kubepods cgroup hierarchy) to the namespace, pod, container and owner of the workload.
+type PodMetadataResolver struct {
+	client   *kubelet.Client
+	interval time.Duration
+
+	mutex      sync.RWMutex
+	containers map[string]PodContainer // by container id
+	listeners  []func()
+}
+
+func NewPodMetadataResolver(client *kubelet.Client, interval time.Duration) *PodMetadataResolver {
+	return &PodMetadataResolver{
+		client:     client,
+		interval:   interval,
+		containers: make(map[string]PodContainer),
+	}
+}
+
+// OnChange registers a function that is called every time the resolved pod metadata changes.
+func (resolver *PodMetadataResolver) OnChange(listener func()) {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+	resolver.listeners = append(resolver.listeners, listener)
+}
+
+// LookupContainer returns the pod metadata of the given container id. The id may be a prefix of the full container
+// id, as used by the cgroup v2 kubernetes_cgrp_* instruments.
+func (resolver *PodMetadataResolver) LookupContainer(containerId string) (PodContainer, bool) {
+	resolver.mutex.RLock()
+	defer resolver.mutex.RUnlock()
+
+	if container, ok := resolver.containers[containerId]; ok {
+		return container, true
+	}
+
+	for id, container := range resolver.containers {
+		if strings.HasPrefix(id, containerId) {
+			return container, true
+		}
+	}
+
+	return PodContainer{}, false
+}
+
+// Containers returns the metadata of all known pod containers.
+func (resolver *PodMetadataResolver) Containers() []PodContainer {
+	resolver.mutex.RLock()
+	defer resolver.mutex.RUnlock()
+
+	containers := make([]PodContainer, 0, len(resolver.containers))
+	for _, container := range resolver.containers {
+		containers = append(containers, container)
+	}
+	return containers
+}
+
+// Refresh queries the kubelet and replaces the resolved metadata. Listeners are only notified if anything changed.
+func (resolver *PodMetadataResolver) Refresh(ctx context.Context) error {
+	pods, err := resolver.client.Pods(ctx)
+	if err != nil {
+		return err
+	}
+
+	containers := make(map[string]PodContainer)
+	for _, pod := range pods {
+		for _, container := range pod.Containers {
+			containers[container.Id] = PodContainer{
+				ContainerId: container.Id,
+				Container:   container.Name,
+				PodUid:      pod.Uid,
+				Pod:         pod.Name,
+				Namespace:   pod.Namespace,
+				OwnerKind:   pod.OwnerKind,
+				OwnerName:   pod.OwnerName,
+				Labels:      pod.Labels,
+			}
+		}
+	}
+
+	resolver.mutex.Lock()
+	changed := !reflect.DeepEqual(resolver.containers, containers)
+	resolver.containers = containers
+	listeners := resolver.listeners
+	resolver.mutex.Unlock()
+
+	if changed {
+		for _, listener := range listeners {
+			listener()
+		}
+	}
+
+	return nil
+}
+
+// Run refreshes the pod metadata in the configured interval until the given channel is closed.
+func (resolver *PodMetadataResolver) Run(done <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-done
+		cancel()
+	}()
+
+	ticker := time.NewTicker(resolver.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := resolver.Refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Println("error reading pods from kubelet", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package telemd
+
+import (
+	"context"
+	"github.com/edgerun/telemd/internal/kubelet"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPodMetadataResolver_Refresh(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"items": [{
+			"metadata": {"name": "web-0", "namespace": "shop", "uid": "6226f8c8-a5fa-4684-b5c2-c758e052c22e",
+				"ownerReferences": [{"kind": "StatefulSet", "name": "web", "controller": true}]},
+			"status": {"containerStatuses": [
+				{"name": "nginx", "containerID": "containerd://2cc54a6877a50da0b6a2a5340dd1e8c5707a1d7d4b363e03b7cde76d2569f0c0"}
+			]}
+		}]}`))
+	}))
+	defer server.Close()
+
+	resolver := NewPodMetadataResolver(kubelet.NewClient(server.URL, "", false), time.Minute)
+
+	changes := 0
+	resolver.OnChange(func() {
+		changes++
+	})
+
+	if err := resolver.Refresh(context.Background()); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if err := resolver.Refresh(context.Background()); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if changes != 1 {
+		t.Error("expected listeners to be notified only once, but was", changes)
+	}
+
+	// cgroup v2 instruments use the first 32 characters of the container id
+	container, ok := resolver.LookupContainer("2cc54a6877a50da0b6a2a5340dd1e8c5")
+	if !ok {
+		t.Fatal("expected container to be resolved")
+	}
+	if container.Namespace != "shop" || container.Pod != "web-0" || container.Container != "nginx" {
+		t.Error("unexpected container metadata", container)
+	}
+	if container.OwnerKind != "StatefulSet" || container.OwnerName != "web" {
+		t.Error("unexpected owner", container.OwnerKind, container.OwnerName)
+	}
+}
@@ -1,84 +1,514 @@
 package telemd
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/edgerun/telemd/internal/docker"
+	"github.com/edgerun/telemd/internal/env"
 	retryingRedis "github.com/edgerun/telemd/internal/redis"
 	"github.com/edgerun/telemd/internal/telem"
 	"github.com/go-redis/redis/v7"
 	"log"
 	"strings"
+	"sync"
+	"time"
+)
+
+// CommandServerState describes the subscription of a RedisCommandServer.
+type CommandServerState string
+
+const (
+	CommandServerStopped     CommandServerState = "stopped"
+	CommandServerSubscribing CommandServerState = "subscribing"
+	CommandServerSubscribed  CommandServerState = "subscribed"
+	// CommandServerRetrying means the subscription failed, and the server waits before it subscribes again
+	CommandServerRetrying CommandServerState = "retrying"
+)
+
+const (
+	commandResubscribeMinBackoff = 1 * time.Second
+	commandResubscribeMaxBackoff = 30 * time.Second
 )
 
 type RedisCommandServer struct {
-	daemon  *Daemon
-	client  *redis.Client
-	stopped chan bool
-	running bool
+	daemon *Daemon
+	auth   *CommandAuthenticator
+
+	// mutex guards the client and the state of the subscribe loop, of which at most one runs at a time
+	mutex  sync.Mutex
+	client *redis.Client
+	state  CommandServerState
+	pubsub *redis.PubSub
+	stop   chan struct{}
+	done   chan struct{}
+
+	reloadListeners []func()
 }
 
 func NewRedisCommandServer(daemon *Daemon, client *redis.Client) *RedisCommandServer {
-	return &RedisCommandServer{
-		daemon:  daemon,
-		client:  client,
-		stopped: make(chan bool),
-		running: false,
+	server := &RedisCommandServer{
+		daemon: daemon,
+		client: client,
+		state:  CommandServerStopped,
+	}
+
+	if len(daemon.cfg.Commands.Keys) > 0 {
+		auth, err := NewCommandAuthenticator(daemon.cfg.Commands.Keys, daemon.cfg.Commands.MaxSkew)
+		if err != nil {
+			log.Fatal("Error reading telemd_command_keys ", err)
+		}
+		log.Println("only accepting signed commands")
+		server.auth = auth
+	}
+
+	if daemon.containers != nil {
+		daemon.containers.OnChange(func() {
+			if err := server.UpdateContainerInfo(); err != nil {
+				log.Println("error while updating container info", err)
+			}
+		})
+	}
+
+	if daemon.pods != nil {
+		daemon.pods.OnChange(func() {
+			if err := server.UpdatePodInfo(); err != nil {
+				log.Println("error while updating pod info", err)
+			}
+		})
+	}
+
+	daemon.netDevices.OnChange(func() {
+		if err := WriteNodeInfoDevices(server.redis(), daemon.cfg.NodeName, "net", daemon.netDevices.Devices()); err != nil {
+			log.Println("error while updating net info", err)
+		}
+	})
+	daemon.diskDevices.OnChange(func() {
+		if err := WriteNodeInfoDevices(server.redis(), daemon.cfg.NodeName, "disk", daemon.diskDevices.Devices()); err != nil {
+			log.Println("error while updating disk info", err)
+		}
+	})
+
+	// the network speed depends on the default interface
+	daemon.defaultIface.OnChange(func() {
+		if err := server.UpdateNodeInfo(); err != nil {
+			log.Println("error while updating node info", err)
+		}
+	})
+
+	return server
+}
+
+// BroadcastCommandTopic is the topic of commands that are executed by all nodes. It is a plain channel name, not a
+// pattern.
+const BroadcastCommandTopic = "telemcmd" + telem.TopicSeparator + "*"
+
+// commandTopics returns the topics a node listens to for commands: its own topic telemcmd/<node>, the broadcast topic
+// telemcmd/*, and telemcmd/group/<group> for each group of the node.
+func commandTopics(node string, groups []string) []string {
+	topics := []string{
+		"telemcmd" + telem.TopicSeparator + node,
+		BroadcastCommandTopic,
+	}
+	for _, group := range groups {
+		topics = append(topics, "telemcmd"+telem.TopicSeparator+"group"+telem.TopicSeparator+group)
 	}
+	return topics
 }
 
+// Run subscribes to the command topics and executes the received commands until Stop is called. If the subscription
+// fails or is lost, the server subscribes again with exponential backoff. Run returns immediately if the server is
+// already running.
 func (server *RedisCommandServer) Run() {
-	topic := "telemcmd" + telem.TopicSeparator + telem.NodeName
+	server.mutex.Lock()
+	if server.state != CommandServerStopped {
+		server.mutex.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	server.stop, server.done = stop, done
+	server.mutex.Unlock()
 
-	pubsub := server.client.Subscribe(topic)
-	channel := pubsub.Channel()
+	defer func() {
+		server.setState(CommandServerStopped, nil)
+		close(done)
+	}()
 
-	server.running = true
+	backoff := commandResubscribeMinBackoff
+	topics := commandTopics(telem.NodeName, server.daemon.cfg.Groups)
+	if server.daemon.cfg.CentralConfig {
+		topics = append(topics, centralConfigTopics(server.redis().Options().DB, telem.NodeName)...)
+	}
 
 	for {
-		select {
-		case msg := <-channel:
-			if msg == nil {
-				// TODO: retry loop
-				log.Println("pubsub empty")
+		pubsub := server.redis().Subscribe(topics...)
+		if !server.setState(CommandServerSubscribing, pubsub) {
+			_ = pubsub.Close()
+			return // stopped in the meantime
+		}
+
+		// wait for the confirmation of the subscription
+		if _, err := pubsub.Receive(); err != nil {
+			_ = pubsub.Close()
+			if !server.setState(CommandServerRetrying, nil) {
 				return
 			}
+			log.Println("error while subscribing to commands, retrying in", backoff, err)
 
-			payload := msg.Payload
-			log.Println("received command", payload)
-
-			switch payload {
-			case "pause":
-				server.daemon.Send(Pause)
-			case "unpause":
-				server.daemon.Send(Unpause)
-			case "info":
-				err := server.UpdateNodeInfo()
-				if err != nil {
-					log.Println("error while updating node info", err)
-				}
-			default:
-				log.Println("unhandled command", payload)
+			select {
+			case <-stop:
+				return
+			case <-time.After(backoff):
 			}
-		case <-server.stopped:
-			server.running = false
-			log.Println("closing pubsub")
-			_ = pubsub.Close()
+			if backoff *= 2; backoff > commandResubscribeMaxBackoff {
+				backoff = commandResubscribeMaxBackoff
+			}
+			continue
+		}
+
+		log.Println("subscribed to commands on", topics)
+		server.setState(CommandServerSubscribed, pubsub)
+		backoff = commandResubscribeMinBackoff
+
+		lost := server.receive(pubsub.Channel(), stop)
+		_ = pubsub.Close()
+		if !lost {
+			return
+		}
+		log.Println("command subscription lost, subscribing again")
+	}
+}
+
+// setState updates the state and the current subscription, unless the server was stopped in the meantime, in which
+// case it returns false.
+func (server *RedisCommandServer) setState(state CommandServerState, pubsub *redis.PubSub) bool {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if state != CommandServerStopped {
+		select {
+		case <-server.stop:
+			return false
+		default:
+		}
+	}
+
+	server.state = state
+	server.pubsub = pubsub
+	return true
+}
+
+// redis returns the client the server currently uses.
+func (server *RedisCommandServer) redis() *redis.Client {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.client
+}
+
+// SetClient replaces the redis client, e.g., after the redis URL has changed. The server must be stopped.
+func (server *RedisCommandServer) SetClient(client *redis.Client) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.client = client
+}
+
+// OnReload registers a function that is called when the config should be reloaded, i.e., on the reload command or
+// when the central config changes.
+func (server *RedisCommandServer) OnReload(listener func()) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.reloadListeners = append(server.reloadListeners, listener)
+}
+
+func (server *RedisCommandServer) requestReload() error {
+	server.mutex.Lock()
+	listeners := server.reloadListeners
+	server.mutex.Unlock()
+
+	if len(listeners) == 0 {
+		return errors.New("reloading the config is not supported")
+	}
+	for _, listener := range listeners {
+		listener()
+	}
+	return nil
+}
+
+// State returns the state of the command subscription.
+func (server *RedisCommandServer) State() CommandServerState {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.state
+}
+
+// receive executes the commands of the channel until it is closed, in which case it returns true, or until the server
+// is stopped.
+func (server *RedisCommandServer) receive(channel <-chan *redis.Message, stop <-chan struct{}) bool {
+	for {
+		select {
+		case msg, ok := <-channel:
+			if !ok || msg == nil {
+				return true
+			}
+			server.handle(msg)
+		case <-stop:
+			return false
+		}
+	}
+}
+
+func (server *RedisCommandServer) handle(msg *redis.Message) {
+	if strings.HasPrefix(msg.Channel, "__keyspace@") {
+		log.Println("central config changed:", msg.Payload, "on", msg.Channel)
+		if err := server.requestReload(); err != nil {
+			log.Println("error while reloading config", err)
+		}
+		return
+	}
+
+	payload := msg.Payload
+	log.Println("received command", payload, "on", msg.Channel)
+
+	if server.auth != nil {
+		verified, err := server.auth.Verify(msg.Channel, payload, time.Now())
+		if err != nil {
+			log.Println("rejecting command:", err)
+			server.daemon.self.RejectedCommand()
 			return
 		}
+		payload = verified
+	}
+
+	var result interface{}
+	cmd, err := ParseCommandMessage(payload)
+	if err == nil {
+		result, err = server.execute(cmd)
+	}
+	if err != nil {
+		log.Println("error while executing command", payload, err)
+	}
+	// a JSON command without id does not expect a reply, neither do plain-string commands
+	if cmd.Id != "" {
+		if err := server.reply(NewCommandReply(cmd.Id, result, err)); err != nil {
+			log.Println("error while replying to command", cmd.Id, err)
+		}
 	}
 }
 
+// snapshotMaxAge is the age after which the last value of a topic is not part of a snapshot anymore.
+const snapshotMaxAge = 10 * time.Minute
+
+// execute runs the command, and returns the payload of commands that return data.
+func (server *RedisCommandServer) execute(cmd CommandMessage) (interface{}, error) {
+	switch cmd.Name {
+	case "pause", "unpause", "enable", "disable", "period", "burst":
+		return nil, server.daemon.Send(Command(cmd.Name), cmd.Args...)
+	case "info":
+		return nil, server.UpdateNodeInfo()
+	case "reload":
+		return nil, server.requestReload()
+	case "health":
+		return server.daemon.InstrumentStats(), server.UpdateHealth()
+	case "snapshot":
+		// a command with id expects the values in the reply, otherwise they are published into their topics
+		if cmd.Id != "" {
+			return snapshotPayload(server.daemon.Snapshot(snapshotMaxAge)), nil
+		}
+		return nil, server.PublishSnapshot()
+	default:
+		return nil, errors.New("unhandled command " + cmd.Name)
+	}
+}
+
+// PublishSnapshot publishes the latest value of every topic again.
+func (server *RedisCommandServer) PublishSnapshot() error {
+	for _, t := range server.daemon.Snapshot(snapshotMaxAge) {
+		if _, err := report(server.redis(), t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotPayload returns the values as list of {"topic": ..., "time": ..., "value": ...} objects.
+func snapshotPayload(values []telem.Telemetry) []map[string]interface{} {
+	payload := make([]map[string]interface{}, len(values))
+	for i, t := range values {
+		payload[i] = map[string]interface{}{
+			"topic": t.Topic,
+			"time":  float64(t.Time.UnixNano()) / float64(time.Second),
+			"value": t.Value,
+		}
+	}
+	return payload
+}
+
+// reply publishes the reply to a command into telemcmd/<node>/reply/<id>.
+func (server *RedisCommandServer) reply(reply CommandReply) error {
+	message, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+
+	topic := "telemcmd" + telem.TopicSeparator + telem.NodeName + telem.TopicSeparator + "reply" + telem.TopicSeparator + reply.Id
+	return server.redis().Publish(topic, message).Err()
+}
+
 func (server *RedisCommandServer) UpdateNodeInfo() error {
-	return WriteNodeInfo(server.client, server.daemon.cfg.NodeName, SysInfo())
+	info := SysInfo(server.daemon.cfg.Mounts.Proc)
+	// only report the devices that are actually monitored
+	info.Net = server.daemon.netDevices.Devices()
+	info.Disk = server.daemon.diskDevices.Devices()
+	info.Groups = server.daemon.cfg.Groups
+
+	err := WriteNodeInfo(server.redis(), server.daemon.cfg.NodeName, info)
+	if err != nil {
+		return err
+	}
+	if err := server.UpdateContainerInfo(); err != nil {
+		return err
+	}
+	return server.UpdatePodInfo()
+}
+
+// UpdateHealth writes the current stats of all instruments.
+func (server *RedisCommandServer) UpdateHealth() error {
+	return WriteHealth(server.redis(), server.daemon.cfg.NodeName, server.daemon.InstrumentStats())
+}
+
+// UpdateContainerInfo writes the metadata of all known containers. It does nothing if container metadata resolution
+// is disabled.
+func (server *RedisCommandServer) UpdateContainerInfo() error {
+	if server.daemon.containers == nil {
+		return nil
+	}
+	return WriteContainerInfo(server.redis(), server.daemon.cfg.NodeName, server.daemon.containers.Containers())
+}
+
+// UpdatePodInfo writes the metadata of all known pod containers. It does nothing if pod metadata resolution is
+// disabled.
+func (server *RedisCommandServer) UpdatePodInfo() error {
+	if server.daemon.pods == nil {
+		return nil
+	}
+	return WritePodInfo(server.redis(), server.daemon.cfg.NodeName, server.daemon.pods.Containers())
 }
 
 func (server *RedisCommandServer) RemoveNodeInfo() error {
-	return RemoveNodeInfo(server.client, server.daemon.cfg.NodeName)
+	if err := RemoveContainerInfo(server.redis(), server.daemon.cfg.NodeName); err != nil {
+		return err
+	}
+	if err := RemovePodInfo(server.redis(), server.daemon.cfg.NodeName); err != nil {
+		return err
+	}
+	if err := RemoveHealth(server.redis(), server.daemon.cfg.NodeName); err != nil {
+		return err
+	}
+	return RemoveNodeInfo(server.redis(), server.daemon.cfg.NodeName)
 }
 
+// Stop ends the subscription, and waits until Run has returned.
 func (server *RedisCommandServer) Stop() {
-	if server.running {
-		server.stopped <- true
+	server.mutex.Lock()
+	if server.state == CommandServerStopped {
+		server.mutex.Unlock()
+		return
+	}
+	close(server.stop)
+	if server.pubsub != nil {
+		// interrupts a pending subscription
+		_ = server.pubsub.Close()
+	}
+	done := server.done
+	server.mutex.Unlock()
+
+	log.Println("closing pubsub")
+	<-done
+}
+
+// centralConfigTimeout bounds reading the central config, so that a reload does not block while redis is unreachable
+const centralConfigTimeout = 5 * time.Second
+
+// centralConfigSettings are the prefixes of the config keys that are read from the central config. As the central
+// config is applied like a reload, it covers the instrument settings only. All other settings, in particular the exec
+// instruments and the command keys, are only read locally, as anyone with write access to redis could otherwise run
+// arbitrary commands on all nodes.
+var centralConfigSettings = []string{
+	"telemd_instruments_enable",
+	"telemd_instruments_disable",
+	"telemd_instrument_timeout",
+	"telemd_period_",
+	"telemd_timeout_",
+	"telemd_net_devices",
+	"telemd_disk_devices",
+	"telemd_textfile_max_age",
+}
+
+func isCentralConfigSetting(key string) bool {
+	for _, prefix := range centralConfigSettings {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadCentralConfig reads the config of the node from the redis hashes telemd.config, which applies to all nodes, and
+// telemd.config:<nodename>, whose values take precedence. The fields of the hashes are the config keys, e.g.,
+// telemd_period_cpu.
+func ReadCentralConfig(client *redis.Client, nodeName string) ([]env.Environment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), centralConfigTimeout)
+	defer cancel()
+	client = client.WithContext(ctx)
+
+	environments := make([]env.Environment, 0, 2)
+	for _, key := range centralConfigKeys(nodeName) {
+		values, err := client.HGetAll(key).Result()
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", key, err)
+		}
+		environments = append(environments, filterCentralConfig(key, values))
+	}
+
+	return environments, nil
+}
+
+// filterCentralConfig returns the environment of the values of the given central config hash, without the settings
+// that can only be set locally, and without periods that could not be set by command either.
+func filterCentralConfig(key string, values map[string]string) env.Environment {
+	for setting, value := range values {
+		if !isCentralConfigSetting(setting) {
+			log.Println("ignoring", setting, "of", key, "which can only be set locally")
+			delete(values, setting)
+			continue
+		}
+		if !strings.HasPrefix(setting, "telemd_period_") {
+			continue
+		}
+		if period, err := time.ParseDuration(value); err == nil {
+			if err := checkCommandPeriod(period); err != nil {
+				log.Println("ignoring", setting, "of", key+":", err)
+				delete(values, setting)
+			}
+		}
+	}
+	return env.NewMapEnvironment(values)
+}
+
+func centralConfigKeys(nodeName string) []string {
+	return []string{"telemd.config", "telemd.config:" + nodeName}
+}
+
+// centralConfigTopics returns the keyspace notification channels of the central config hashes in the given database.
+func centralConfigTopics(db int, nodeName string) []string {
+	keys := centralConfigKeys(nodeName)
+	topics := make([]string, len(keys))
+	for i, key := range keys {
+		topics[i] = fmt.Sprintf("__keyspace@%d__:%s", db, key)
 	}
+	return topics
 }
 
 func WriteNodeInfo(client *redis.Client, nodeName string, info NodeInfo) error {
@@ -94,64 +524,190 @@ func WriteNodeInfo(client *redis.Client, nodeName string, info NodeInfo) error {
 	multi.HSet(key, "disk", strings.Join(info.Disk, " "))
 	multi.HSet(key, "net", strings.Join(info.Net, " "))
 	multi.HSet(key, "netspeed", info.NetSpeed)
+	multi.HSet(key, "groups", strings.Join(info.Groups, " "))
+
+	if len(info.Wifi) > 0 {
+		wifi, err := json.Marshal(info.Wifi)
+		if err != nil {
+			return err
+		}
+		multi.HSet(key, "wifi", string(wifi))
+	} else {
+		multi.HDel(key, "wifi")
+	}
 
 	_, err := multi.Exec()
 	return err
 }
 
+// WriteNodeInfoDevices updates the given device field (net or disk) of the node info.
+func WriteNodeInfoDevices(client *redis.Client, nodeName string, field string, devices []string) error {
+	return client.HSet("telemd.info:"+nodeName, field, strings.Join(devices, " ")).Err()
+}
+
 func RemoveNodeInfo(client *redis.Client, nodeName string) error {
 	return client.Del("telemd.info:" + nodeName).Err()
 }
 
+// WriteContainerInfo replaces the hash telemd.containers:<nodeName> with the given containers. The hash maps the
+// short container id (as used in the docker_cgrp_* topics) to a JSON document containing the container's metadata.
+func WriteContainerInfo(client *redis.Client, nodeName string, containers []docker.Container) error {
+	key := "telemd.containers:" + nodeName
+
+	multi := client.TxPipeline()
+	multi.Del(key)
+
+	for _, container := range containers {
+		value, err := json.Marshal(container)
+		if err != nil {
+			return err
+		}
+		multi.HSet(key, shortContainerId(container.Id), value)
+	}
+
+	_, err := multi.Exec()
+	return err
+}
+
+func RemoveContainerInfo(client *redis.Client, nodeName string) error {
+	return client.Del("telemd.containers:" + nodeName).Err()
+}
+
+// WriteHealth replaces the hash telemd.health:<nodeName> with the given instrument stats. The hash maps the
+// instrument name to a JSON document containing the stats.
+func WriteHealth(client *redis.Client, nodeName string, stats map[string]InstrumentStats) error {
+	key := "telemd.health:" + nodeName
+
+	multi := client.TxPipeline()
+	multi.Del(key)
+
+	for name, instrumentStats := range stats {
+		value, err := json.Marshal(instrumentStats)
+		if err != nil {
+			return err
+		}
+		multi.HSet(key, name, value)
+	}
+
+	_, err := multi.Exec()
+	return err
+}
+
+func RemoveHealth(client *redis.Client, nodeName string) error {
+	return client.Del("telemd.health:" + nodeName).Err()
+}
+
+// WritePodInfo replaces the hash telemd.pods:<nodeName> with the given pod containers. The hash maps the full
+// container id to a JSON document containing the namespace, pod, container, owner and labels of the workload.
+func WritePodInfo(client *redis.Client, nodeName string, containers []PodContainer) error {
+	key := "telemd.pods:" + nodeName
+
+	multi := client.TxPipeline()
+	multi.Del(key)
+
+	for _, container := range containers {
+		value, err := json.Marshal(container)
+		if err != nil {
+			return err
+		}
+		multi.HSet(key, container.ContainerId, value)
+	}
+
+	_, err := multi.Exec()
+	return err
+}
+
+func RemovePodInfo(client *redis.Client, nodeName string) error {
+	return client.Del("telemd.pods:" + nodeName).Err()
+}
+
+func shortContainerId(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
 type RedisReporter struct {
 	channel  telem.TelemetryChannel
+	events   telem.EventChannel
+	mutex    sync.Mutex
 	client   *redis.Client
 	stopChan chan bool
 	running  bool
+	stats    *SelfStats
 }
 
 func NewRedisReporter(daemon *Daemon, client *redis.Client) *RedisReporter {
 	return &RedisReporter{
 		channel:  daemon.telemetry,
+		events:   daemon.events,
 		client:   client,
 		stopChan: make(chan bool, 10),
 		running:  false,
+		stats:    daemon.self,
 	}
 }
 
-// Run iterates over the configured TelemetryChannel and reports
-// received Telemetry data through the configured redis client.
+// Run iterates over the configured TelemetryChannel and EventChannel and reports
+// received Telemetry data and Events through the configured redis client.
 func (reporter *RedisReporter) Run() {
 	reporter.running = true
+	events := reporter.events.Channel()
 
 	for {
+		var receivers int64
+		var err error
+
 		select {
 		case t := <-reporter.channel.Channel():
-			receivers, err := report(reporter.client, t)
-
-			if err != nil {
-				reporter.running = false
-
-				_, ok := err.(*retryingRedis.ClientClosedError)
-				if ok {
-					log.Println("retry client was closed")
-					return
-				}
-
-				// TODO proper error handling
-				panic(err)
-			}
-
-			if receivers == 0 {
-				// TODO: if there are no subscribers, we could pause this ticker for X seconds and try again
+			receivers, err = report(reporter.redis(), t)
+		case e, ok := <-events:
+			if !ok {
+				events = nil // closed, the daemon is shutting down
+				continue
 			}
+			receivers, err = reportEvent(reporter.redis(), e)
 		case <-reporter.stopChan:
 			reporter.running = false
 			return
 		}
+
+		if err != nil {
+			reporter.stats.Dropped("redis")
+			reporter.running = false
+
+			_, ok := err.(*retryingRedis.ClientClosedError)
+			if ok {
+				log.Println("retry client was closed")
+				return
+			}
+
+			// TODO proper error handling
+			panic(err)
+		}
+
+		reporter.stats.Published("redis")
+
+		if receivers == 0 {
+			// TODO: if there are no subscribers, we could pause this ticker for X seconds and try again
+		}
 	}
 }
 
+func (reporter *RedisReporter) redis() *redis.Client {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	return reporter.client
+}
+
+// SetClient replaces the redis client, e.g., after the redis URL has changed.
+func (reporter *RedisReporter) SetClient(client *redis.Client) {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	reporter.client = client
+}
+
 func (reporter *RedisReporter) Stop() {
 	if reporter.running {
 		reporter.stopChan <- true
@@ -171,3 +727,26 @@ func report(client *redis.Client, t telem.Telemetry) (int64, error) {
 	}
 	return cmd.Val(), nil
 }
+
+// reportEvent publishes the given event as JSON object containing the time, the event type, and the event's attributes.
+func reportEvent(client *redis.Client, e telem.Event) (int64, error) {
+	channel := fmt.Sprintf("telem%s%s%s%s", telem.TopicSeparator, e.Node, telem.TopicSeparator, e.Topic())
+
+	data := make(map[string]interface{}, len(e.Attributes)+2)
+	for k, v := range e.Attributes {
+		data[k] = v
+	}
+	data["time"] = float64(e.Time.UnixNano()) / float64(time.Second)
+	data["type"] = e.Type
+
+	message, err := json.Marshal(data)
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := client.Publish(channel, message)
+	if cmd.Err() != nil {
+		return 0, cmd.Err()
+	}
+	return cmd.Val(), nil
+}
@@ -0,0 +1,128 @@
+package telemd
+
+import (
+	"github.com/go-redis/redis/v7"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCommandTopics(t *testing.T) {
+	topics := commandTopics("pi-01", []string{"pis", "site-a"})
+
+	expected := []string{"telemcmd/pi-01", "telemcmd/*", "telemcmd/group/pis", "telemcmd/group/site-a"}
+	if !reflect.DeepEqual(topics, expected) {
+		t.Error("Unexpected topics", topics)
+	}
+
+	topics = commandTopics("pi-01", nil)
+	if !reflect.DeepEqual(topics, []string{"telemcmd/pi-01", "telemcmd/*"}) {
+		t.Error("Unexpected topics", topics)
+	}
+}
+
+func TestRedisCommandServer_RetriesUnreachableRedis(t *testing.T) {
+	// reserve a port that nothing listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	client := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+	defer client.Close()
+
+	// the test daemon has no device sets that NewRedisCommandServer could observe
+	server := &RedisCommandServer{daemon: newCommandTestDaemon(), client: client, state: CommandServerStopped}
+	if server.State() != CommandServerStopped {
+		t.Error("Expected new server to be stopped, was", server.State())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		server.Run()
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for server.State() != CommandServerRetrying {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected server to retry, was", server.State())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// a second loop must not be started while the first is running
+	second := make(chan struct{})
+	go func() {
+		server.Run()
+		close(second)
+	}()
+	select {
+	case <-second:
+	case <-time.After(time.Second):
+		t.Fatal("Expected second Run to return immediately")
+	}
+
+	server.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Run to return after Stop")
+	}
+
+	if server.State() != CommandServerStopped {
+		t.Error("Expected stopped server, was", server.State())
+	}
+
+	// stopping a stopped server is a no-op
+	server.Stop()
+}
+
+func TestCentralConfigTopics(t *testing.T) {
+	topics := centralConfigTopics(2, "pi-01")
+
+	expected := []string{"__keyspace@2__:telemd.config", "__keyspace@2__:telemd.config:pi-01"}
+	if !reflect.DeepEqual(topics, expected) {
+		t.Error("Unexpected topics", topics)
+	}
+}
+
+func TestIsCentralConfigSetting(t *testing.T) {
+	for _, key := range []string{"telemd_period_cpu", "telemd_instruments_disable", "telemd_timeout_exec_queues",
+		"telemd_net_devices_exclude"} {
+		if !isCentralConfigSetting(key) {
+			t.Error("Expected a central setting", key)
+		}
+	}
+	for _, key := range []string{"telemd_exec_instruments", "telemd_exec_queues_command", "telemd_command_keys",
+		"telemd_textfile_dir", "telemd_groups", "telemd_redis_url", "telemd_nodename"} {
+		if isCentralConfigSetting(key) {
+			t.Error("Expected a local setting", key)
+		}
+	}
+}
+
+func TestRedisCommandServer_Reload(t *testing.T) {
+	server := &RedisCommandServer{daemon: newCommandTestDaemon(), state: CommandServerStopped}
+	defer server.daemon.Stop()
+
+	if _, err := server.execute(CommandMessage{Name: "reload"}); err == nil {
+		t.Error("Expected an error without reload listener")
+	}
+
+	reloads := 0
+	server.OnReload(func() { reloads++ })
+
+	if _, err := server.execute(CommandMessage{Name: "reload"}); err != nil {
+		t.Error("Unexpected error", err)
+	}
+	// keyspace notifications are not commands, and need no signature
+	server.handle(&redis.Message{Channel: "__keyspace@0__:telemd.config", Payload: "hset"})
+
+	if reloads != 2 {
+		t.Error("Expected two reloads, got", reloads)
+	}
+}
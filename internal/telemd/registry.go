@@ -0,0 +1,119 @@
+package telemd
+
+import (
+	"fmt"
+	"github.com/edgerun/telemd/internal/nl80211"
+	"io"
+	"runtime"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// InstrumentEnv holds what instruments are constructed with: the config and the resources the daemon shares between
+// instruments, as well as the platform telemd runs on.
+type InstrumentEnv struct {
+	Config *Config
+	// Arch is the GOARCH telemd runs on, Cgroup the cgroup version of the host (v1 or v2)
+	Arch   string
+	Cgroup string
+
+	NetDevices  *DeviceSet
+	DiskDevices *DeviceSet
+	Containers  *ContainerMetadataCache
+	Wifi        *nl80211.Client
+	Self        *SelfStats
+	Stats       func() map[string]InstrumentStats
+}
+
+// InstrumentOption describes a config key an instrument reads.
+type InstrumentOption struct {
+	Key         string
+	Default     string
+	Description string
+}
+
+// InstrumentSpec describes an instrument that the daemon can run.
+type InstrumentSpec struct {
+	Name        string
+	Description string
+	// Period is the default period, which can be overwritten by telemd_period_<name>
+	Period time.Duration
+	// Options are the config keys of the instrument, besides telemd_period_<name> and telemd_timeout_<name>
+	Options []InstrumentOption
+	// Available reports whether the instrument can run on the platform, e.g., whether the files it reads exist. nil
+	// means the instrument is always available.
+	Available func(env *InstrumentEnv) bool
+	// New creates the instrument. It may return nil if the instrument cannot run with the given resources.
+	New func(env *InstrumentEnv) ContextInstrument
+}
+
+var instrumentRegistry = make(map[string]InstrumentSpec)
+
+// RegisterInstrument adds an instrument to the registry. Instruments register themselves in an init function of the
+// file they are implemented in. It panics if an instrument with the same name was already registered.
+func RegisterInstrument(spec InstrumentSpec) {
+	if spec.Name == "" || spec.New == nil {
+		panic("instrument requires a name and a constructor")
+	}
+	if _, ok := instrumentRegistry[spec.Name]; ok {
+		panic("instrument " + spec.Name + " registered twice")
+	}
+	instrumentRegistry[spec.Name] = spec
+}
+
+// LookupInstrument returns the registered instrument with the given name.
+func LookupInstrument(name string) (InstrumentSpec, bool) {
+	spec, ok := instrumentRegistry[name]
+	return spec, ok
+}
+
+// RegisteredInstruments returns all registered instruments ordered by name.
+func RegisteredInstruments() []InstrumentSpec {
+	specs := make([]InstrumentSpec, 0, len(instrumentRegistry))
+	for _, spec := range instrumentRegistry {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].Name < specs[j].Name
+	})
+	return specs
+}
+
+// IsAvailable returns whether the instrument can run in the given environment.
+func (spec InstrumentSpec) IsAvailable(env *InstrumentEnv) bool {
+	return spec.Available == nil || spec.Available(env)
+}
+
+// ListInstruments writes a description of all registered instruments, and whether they are available on this host.
+func ListInstruments(w io.Writer, cfg *Config) error {
+	env := &InstrumentEnv{Config: cfg, Arch: runtime.GOARCH, Cgroup: checkCgroup()}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tPERIOD\tAVAILABLE\tDESCRIPTION")
+
+	for _, spec := range RegisteredInstruments() {
+		available := "yes"
+		if !spec.IsAvailable(env) {
+			available = "no"
+		}
+		period := spec.Period
+		if p, ok := cfg.Instruments.Periods[spec.Name]; ok {
+			period = p
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", spec.Name, period, available, spec.Description)
+
+		for _, option := range spec.Options {
+			value := option.Default
+			if value == "" {
+				value = "-"
+			}
+			_, _ = fmt.Fprintf(tw, "\t\t\t  %s (default: %s) %s\n", option.Key, value, option.Description)
+		}
+	}
+
+	_, _ = fmt.Fprintln(tw)
+	_, _ = fmt.Fprintln(tw, "exec_<name> instruments are configured via telemd_exec_instruments and telemd_exec_<name>_command")
+
+	return tw.Flush()
+}
@@ -0,0 +1,87 @@
+package telemd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegisteredInstruments(t *testing.T) {
+	specs := RegisteredInstruments()
+
+	for i := 1; i < len(specs); i++ {
+		if specs[i-1].Name >= specs[i].Name {
+			t.Error("Expected instruments ordered by name, got", specs[i-1].Name, "before", specs[i].Name)
+		}
+	}
+
+	for _, name := range []string{"cpu", "freq", "ram", "net", "disk", "psi_io", "docker_cgrp_net", "podman_cgrp_cpu", "wifi", "telemd", "textfile"} {
+		if _, ok := LookupInstrument(name); !ok {
+			t.Error("Expected instrument to be registered:", name)
+		}
+	}
+}
+
+func TestRegisterInstrument_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering an instrument twice to panic")
+		}
+	}()
+
+	RegisterInstrument(InstrumentSpec{
+		Name: "cpu",
+		New: func(env *InstrumentEnv) ContextInstrument {
+			return &CpuUtilInstrument{}
+		},
+	})
+}
+
+func TestNewDefaultConfig_PeriodsFromRegistry(t *testing.T) {
+	cfg := NewDefaultConfig()
+
+	for _, spec := range RegisteredInstruments() {
+		if cfg.Instruments.Periods[spec.Name] != spec.Period {
+			t.Errorf("Expected default period of %s to be %v, got %v", spec.Name, spec.Period, cfg.Instruments.Periods[spec.Name])
+		}
+	}
+	if cfg.Instruments.Periods["cpu"] != 500*time.Millisecond {
+		t.Error("Unexpected period of cpu", cfg.Instruments.Periods["cpu"])
+	}
+}
+
+func TestConfig_IsEnabled(t *testing.T) {
+	cfg := NewDefaultConfig()
+	if !cfg.isEnabled("cpu") {
+		t.Error("Expected all instruments to be enabled by default")
+	}
+
+	cfg.Instruments.Enable = []string{"cpu", "ram"}
+	if !cfg.isEnabled("cpu") || cfg.isEnabled("net") {
+		t.Error("Expected only enabled instruments to be enabled")
+	}
+
+	cfg.Instruments.Disable = []string{"cpu"}
+	if cfg.isEnabled("cpu") || !cfg.isEnabled("net") {
+		t.Error("Expected disable to be preferred over enable")
+	}
+}
+
+func TestListInstruments(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := ListInstruments(&buf, NewDefaultConfig()); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "telemd_textfile_dir") {
+		t.Error("Expected the options of instruments to be listed")
+	}
+	for _, spec := range RegisteredInstruments() {
+		if !strings.Contains(out, spec.Name) {
+			t.Error("Expected instrument to be listed:", spec.Name)
+		}
+	}
+}
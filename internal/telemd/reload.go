@@ -0,0 +1,157 @@
+package telemd
+
+import (
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Reload applies the instrument settings of the given config to the running daemon, without restarting instruments
+// whose settings have not changed:
+//
+//   - instruments that are enabled or disabled by the new config are started or stopped
+//   - instruments whose timeout, exec command or textfile options changed are restarted
+//   - changed periods are applied to the running instruments, overriding periods set by command
+//   - net and disk device patterns apply from the next device discovery
+//
+// The redis settings are taken over, but connecting to a new redis URL is up to the caller. All other settings are
+// only read at startup, changing them is logged as requiring a restart.
+func (daemon *Daemon) Reload(cfg *Config) {
+	for _, setting := range restartRequired(daemon.cfg, cfg) {
+		log.Println("ignoring changed", setting, "which requires a restart")
+	}
+
+	daemon.mutex.Lock()
+
+	old := NewConfig()
+	old.Instruments = daemon.cfg.Instruments
+
+	current := daemon.cfg
+	current.Instruments = cfg.Instruments
+	current.Instruments.DiscoveryInterval = old.Instruments.DiscoveryInterval
+	current.Redis = cfg.Redis
+
+	for _, name := range daemon.reloadCandidates() {
+		_, running := daemon.tickers[name]
+		enabled := current.isEnabled(name) && current.hasInstrument(name)
+		changed := instrumentSettingsChanged(name, old, current)
+
+		switch {
+		case running && (!enabled || changed):
+			_ = daemon.disableInstrument(name)
+			if enabled {
+				if err := daemon.enableInstrument(name); err != nil {
+					log.Println("error while restarting instrument", name, err)
+				}
+			}
+		case running:
+			if period := current.Instruments.Periods[name]; period != old.Instruments.Periods[name] {
+				log.Println("setting period of", name, "to", period)
+				delete(daemon.periods, name)
+				daemon.applyPeriod(name)
+			}
+		case enabled && (!old.isEnabled(name) || changed):
+			// instruments that the old config enabled as well were disabled by command or are not available
+			if err := daemon.enableInstrument(name); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+
+	daemon.mutex.Unlock()
+
+	if !reflect.DeepEqual(old.Instruments.Net, current.Instruments.Net) {
+		log.Println("changing net device patterns to", current.Instruments.Net.Devices, "excluding", current.Instruments.Net.Exclude)
+		daemon.netDevices.SetFilter(current.Instruments.Net.Devices, current.Instruments.Net.Exclude)
+	}
+	if !reflect.DeepEqual(old.Instruments.Disk, current.Instruments.Disk) {
+		log.Println("changing disk device patterns to", current.Instruments.Disk.Devices, "excluding", current.Instruments.Disk.Exclude)
+		daemon.diskDevices.SetFilter(current.Instruments.Disk.Devices, current.Instruments.Disk.Exclude)
+	}
+}
+
+// reloadCandidates returns the names of the running instruments and of all instruments of the config, ordered by name.
+// The daemon's mutex must be held.
+func (daemon *Daemon) reloadCandidates() []string {
+	set := make(map[string]bool)
+	for name := range daemon.tickers {
+		set[name] = true
+	}
+	for _, spec := range RegisteredInstruments() {
+		set[spec.Name] = true
+	}
+	for name := range daemon.cfg.Instruments.Exec {
+		set["exec_"+name] = true
+	}
+
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hasInstrument returns whether the instrument is registered, or an exec instrument of the config.
+func (cfg *Config) hasInstrument(name string) bool {
+	if strings.HasPrefix(name, "exec_") {
+		_, ok := cfg.Instruments.Exec[strings.TrimPrefix(name, "exec_")]
+		return ok
+	}
+	_, ok := LookupInstrument(name)
+	return ok
+}
+
+// timeout returns the measurement deadline of the instrument.
+func (cfg *Config) timeout(name string) time.Duration {
+	if timeout, ok := cfg.Instruments.Timeouts[name]; ok {
+		return timeout
+	}
+	return cfg.Instruments.Timeout
+}
+
+// instrumentSettingsChanged returns whether the settings that the instrument was created with differ between the
+// configs, so that the instrument has to be restarted.
+func instrumentSettingsChanged(name string, old *Config, new *Config) bool {
+	if old.timeout(name) != new.timeout(name) {
+		return true
+	}
+	if strings.HasPrefix(name, "exec_") {
+		name = strings.TrimPrefix(name, "exec_")
+		return old.Instruments.Exec[name] != new.Instruments.Exec[name]
+	}
+	if name == "textfile" {
+		return old.Instruments.Textfile != new.Instruments.Textfile
+	}
+	return false
+}
+
+// restartRequired returns the settings that differ between the configs, but can only be applied at startup.
+func restartRequired(old *Config, new *Config) []string {
+	settings := []struct {
+		name    string
+		changed bool
+	}{
+		{"telemd_nodename", old.NodeName != new.NodeName},
+		{"telemd_groups", !reflect.DeepEqual(old.Groups, new.Groups)},
+		{"telemd_config_watch", old.WatchConfig != new.WatchConfig},
+		{"telemd_central_config", old.CentralConfig != new.CentralConfig},
+		{"telemd_proc_mount", old.Mounts != new.Mounts},
+		{"telemd_device_discovery_interval", old.Instruments.DiscoveryInterval != new.Instruments.DiscoveryInterval},
+		{"command settings", !reflect.DeepEqual(old.Commands, new.Commands)},
+		{"ingest settings", old.Ingest != new.Ingest},
+		{"docker settings", old.Docker != new.Docker},
+		{"kubelet settings", old.Kubelet != new.Kubelet},
+		{"event settings", old.Events != new.Events},
+	}
+
+	var changed []string
+	for _, setting := range settings {
+		if setting.changed {
+			changed = append(changed, setting.name)
+		}
+	}
+	return changed
+}
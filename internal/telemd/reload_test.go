@@ -0,0 +1,111 @@
+package telemd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDaemon_Reload(t *testing.T) {
+	daemon := newCommandTestDaemon()
+	defer daemon.Stop()
+
+	daemon.cfg.Instruments.Enable = []string{"procs", "load", "ram"}
+	for _, name := range daemon.cfg.Instruments.Enable {
+		if err := daemon.EnableInstrument(name); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+	}
+	procs, ram := daemon.tickers["procs"], daemon.tickers["ram"]
+
+	cfg := NewDefaultConfig()
+	cfg.Instruments.Enable = []string{"procs", "ram", "cpu"}
+	cfg.Instruments.Periods["procs"] = 50 * time.Millisecond
+	cfg.Instruments.Timeouts["ram"] = time.Second
+
+	daemon.Reload(cfg)
+
+	stats := daemon.InstrumentStats()
+	if _, ok := stats["load"]; ok {
+		t.Error("Expected load to be disabled")
+	}
+	if _, ok := stats["cpu"]; !ok {
+		t.Error("Expected cpu to be enabled")
+	}
+	if daemon.tickers["procs"] != procs {
+		t.Error("Expected procs to keep running, as only its period changed")
+	}
+	if !hasPeriod(daemon, "procs", 50*time.Millisecond) {
+		t.Error("Expected the new period to be applied")
+	}
+	if daemon.tickers["ram"] == ram {
+		t.Error("Expected ram to be restarted with the new timeout")
+	}
+}
+
+func TestDaemon_ReloadExecInstrument(t *testing.T) {
+	daemon := newCommandTestDaemon()
+	defer daemon.Stop()
+
+	cfg := NewDefaultConfig()
+	cfg.Instruments.Enable = []string{"exec_queues"}
+	cfg.Instruments.Exec["queues"] = "echo 1"
+	cfg.Instruments.Periods["exec_queues"] = 10 * time.Second
+
+	daemon.Reload(cfg)
+
+	if _, ok := daemon.InstrumentStats()["exec_queues"]; !ok {
+		t.Fatal("Expected the new exec instrument to be enabled")
+	}
+
+	cfg = NewDefaultConfig()
+	cfg.Instruments.Enable = []string{"exec_queues"}
+
+	daemon.Reload(cfg)
+
+	if _, ok := daemon.InstrumentStats()["exec_queues"]; ok {
+		t.Error("Expected the removed exec instrument to be disabled")
+	}
+}
+
+func TestDaemon_ReloadCentralConfigPeriod(t *testing.T) {
+	daemon := newCommandTestDaemon()
+	defer daemon.Stop()
+
+	if err := daemon.EnableInstrument("procs"); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	central := filterCentralConfig("telemd.config", map[string]string{
+		"telemd_period_procs": "0s",
+		"telemd_period_load":  "-1s",
+	})
+	cfg, err := LoadConfig("/nonexistent/config.ini", central)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	cfg.Instruments.Enable = []string{"procs"}
+
+	// a zero period would make the ticker panic
+	daemon.Reload(cfg)
+
+	if !hasPeriod(daemon, "procs", daemon.cfg.Instruments.Periods["procs"]) ||
+		daemon.cfg.Instruments.Periods["procs"] <= 0 {
+		t.Error("Expected the period of the central config to be ignored, got", daemon.cfg.Instruments.Periods["procs"])
+	}
+	if cfg.Instruments.Periods["load"] <= 0 {
+		t.Error("Expected the negative period of the central config to be ignored")
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	old, new := NewDefaultConfig(), NewDefaultConfig()
+	new.NodeName = "other"
+	new.Ingest.StatsdAddr = ":8125"
+	new.Instruments.Periods["cpu"] = time.Second
+
+	settings := restartRequired(old, new)
+	if !reflect.DeepEqual(settings, []string{"telemd_nodename", "ingest settings"}) {
+		t.Error("Unexpected settings", settings)
+	}
+}
@@ -0,0 +1,221 @@
+package telemd
+
+import (
+	"bufio"
+	"errors"
+	"github.com/edgerun/telemd/internal/telem"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// route flags, see include/uapi/linux/route.h
+const (
+	routeFlagUp     = 0x0001
+	routeFlagReject = 0x0200
+)
+
+// InfoDefaultIface is the type of the info event that is reported when the default network interface changes.
+const InfoDefaultIface = "net/default_iface"
+
+var errNoDefaultRoute = errors.New("no default route")
+
+// defaultRoute is a route to 0.0.0.0/0 or ::/0 of a particular interface.
+type defaultRoute struct {
+	Iface  string
+	Metric uint32
+}
+
+// parseIpv4DefaultRoutes parses the default routes from the contents of /proc/net/route.
+func parseIpv4DefaultRoutes(r io.Reader) []defaultRoute {
+	var routes []defaultRoute
+
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // skip header
+
+	for scanner.Scan() {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 {
+			continue
+		}
+		if fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&routeFlagUp == 0 || flags&routeFlagReject != 0 {
+			continue
+		}
+		metric, err := strconv.ParseUint(fields[6], 10, 32)
+		if err != nil {
+			continue
+		}
+		routes = append(routes, defaultRoute{Iface: fields[0], Metric: uint32(metric)})
+	}
+
+	return routes
+}
+
+// parseIpv6DefaultRoutes parses the default routes from the contents of /proc/net/ipv6_route.
+func parseIpv6DefaultRoutes(r io.Reader) []defaultRoute {
+	var routes []defaultRoute
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// Destination PrefixLen Source PrefixLen NextHop Metric RefCnt Use Flags Iface
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		if strings.Trim(fields[0], "0") != "" || fields[1] != "00" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[8], 16, 32)
+		if err != nil || flags&routeFlagUp == 0 || flags&routeFlagReject != 0 {
+			continue
+		}
+		if fields[9] == "lo" {
+			continue
+		}
+		metric, err := strconv.ParseUint(fields[5], 16, 32)
+		if err != nil {
+			continue
+		}
+		routes = append(routes, defaultRoute{Iface: fields[9], Metric: uint32(metric)})
+	}
+
+	return routes
+}
+
+// findDefaultIface returns the interface of the IPv4 default route with the lowest metric, or of the IPv6 default route
+// with the lowest metric if there is no IPv4 default route. The metrics of both families are not comparable, e.g., the
+// kernel assigns IPv6 routes a metric of 1024 by default.
+func findDefaultIface(procMount string) (string, error) {
+	if f, err := os.Open(procMount + "/net/route"); err == nil {
+		routes := parseIpv4DefaultRoutes(f)
+		_ = f.Close()
+		if len(routes) > 0 {
+			return lowestMetric(routes).Iface, nil
+		}
+	}
+	if f, err := os.Open(procMount + "/net/ipv6_route"); err == nil {
+		routes := parseIpv6DefaultRoutes(f)
+		_ = f.Close()
+		if len(routes) > 0 {
+			return lowestMetric(routes).Iface, nil
+		}
+	}
+
+	return "", errNoDefaultRoute
+}
+
+// lowestMetric returns the route with the lowest metric of the given non-empty routes.
+func lowestMetric(routes []defaultRoute) defaultRoute {
+	best := routes[0]
+	for _, route := range routes[1:] {
+		if route.Metric < best.Metric {
+			best = route
+		}
+	}
+	return best
+}
+
+// DefaultIfaceMonitor periodically re-evaluates the default network interface, and reports a change as info event
+// into the topic telem/<node>/events/info.
+type DefaultIfaceMonitor struct {
+	procMount string
+	interval  time.Duration
+	events    telem.EventChannel
+
+	mutex     sync.RWMutex
+	iface     string
+	listeners []func()
+}
+
+func NewDefaultIfaceMonitor(events telem.EventChannel, interval time.Duration, procMount string) *DefaultIfaceMonitor {
+	return &DefaultIfaceMonitor{
+		procMount: procMount,
+		interval:  interval,
+		events:    events,
+	}
+}
+
+// OnChange registers a function that is called after the default interface has changed.
+func (monitor *DefaultIfaceMonitor) OnChange(listener func()) {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	monitor.listeners = append(monitor.listeners, listener)
+}
+
+// Iface returns the current default interface, or an empty string if there is no default route.
+func (monitor *DefaultIfaceMonitor) Iface() string {
+	monitor.mutex.RLock()
+	defer monitor.mutex.RUnlock()
+	return monitor.iface
+}
+
+// Run re-evaluates the default interface in the configured interval until the given channel is closed.
+func (monitor *DefaultIfaceMonitor) Run(done <-chan struct{}) {
+	ticker := time.NewTicker(monitor.interval)
+	defer ticker.Stop()
+
+	monitor.update() // initialize state
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			event, changed := monitor.update()
+			if !changed {
+				continue
+			}
+
+			log.Println("default network interface changed to", event.Attributes["value"])
+			monitor.notify()
+
+			select {
+			case <-done:
+				return
+			case monitor.events.Channel() <- event:
+			}
+		}
+	}
+}
+
+// update reads the current default interface and returns the event describing the change, if it has changed.
+func (monitor *DefaultIfaceMonitor) update() (telem.Event, bool) {
+	iface, err := findDefaultIface(monitor.procMount)
+	if err != nil && err != errNoDefaultRoute {
+		log.Println("error reading default route", err)
+		return telem.Event{}, false
+	}
+
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	previous := monitor.iface
+	if iface == previous {
+		return telem.Event{}, false
+	}
+	monitor.iface = iface
+
+	return telem.NewEvent("info", InfoDefaultIface, map[string]string{
+		"value":    iface,
+		"previous": previous,
+	}), true
+}
+
+func (monitor *DefaultIfaceMonitor) notify() {
+	monitor.mutex.RLock()
+	listeners := monitor.listeners
+	monitor.mutex.RUnlock()
+
+	for _, listener := range listeners {
+		listener()
+	}
+}
@@ -0,0 +1,96 @@
+package telemd
+
+import (
+	"github.com/edgerun/telemd/internal/telem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testIpv4Routes = `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+wlan0	00000000	0100A8C0	0003	0	0	600	00000000	0	0	0
+eth0	00000000	0101A8C0	0003	0	0	100	00000000	0	0	0
+eth0	0001A8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+`
+
+const testIpv6Routes = `00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000032 00000001 00000000 00000003    wg0
+fd000000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001   eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo
+`
+
+func TestParseIpv4DefaultRoutes(t *testing.T) {
+	routes := parseIpv4DefaultRoutes(strings.NewReader(testIpv4Routes))
+
+	if len(routes) != 2 {
+		t.Fatal("expected 2 default routes, got", routes)
+	}
+	if routes[0] != (defaultRoute{Iface: "wlan0", Metric: 600}) {
+		t.Error("unexpected route", routes[0])
+	}
+	if routes[1] != (defaultRoute{Iface: "eth0", Metric: 100}) {
+		t.Error("unexpected route", routes[1])
+	}
+}
+
+func TestParseIpv6DefaultRoutes(t *testing.T) {
+	routes := parseIpv6DefaultRoutes(strings.NewReader(testIpv6Routes))
+
+	if len(routes) != 1 {
+		t.Fatal("expected 1 default route, got", routes)
+	}
+	if routes[0] != (defaultRoute{Iface: "wg0", Metric: 50}) {
+		t.Error("unexpected route", routes[0])
+	}
+}
+
+func TestDefaultIfaceMonitor_update(t *testing.T) {
+	proc, err := ioutil.TempDir("", "telemd-proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(proc)
+
+	if err := os.Mkdir(filepath.Join(proc, "net"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeRoutes := func(ipv4 string, ipv6 string) {
+		if err := ioutil.WriteFile(filepath.Join(proc, "net", "route"), []byte(ipv4), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(proc, "net", "ipv6_route"), []byte(ipv6), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	monitor := NewDefaultIfaceMonitor(telem.NewEventChannel(), time.Second, proc)
+
+	writeRoutes(testIpv4Routes, "")
+	if _, changed := monitor.update(); !changed || monitor.Iface() != "eth0" {
+		t.Error("expected default interface eth0, was", monitor.Iface())
+	}
+	if _, changed := monitor.update(); changed {
+		t.Error("expected no change")
+	}
+
+	// the metrics of the ipv6 routes are not compared to the ones of the ipv4 routes
+	writeRoutes(testIpv4Routes, testIpv6Routes)
+	if _, changed := monitor.update(); changed {
+		t.Error("expected the ipv4 default route to take precedence, got", monitor.Iface())
+	}
+
+	// without an ipv4 default route, the ipv6 default route is used
+	writeRoutes("", testIpv6Routes)
+	event, changed := monitor.update()
+	if !changed {
+		t.Fatal("expected a change")
+	}
+	if event.Kind != "info" || event.Type != InfoDefaultIface {
+		t.Error("unexpected event", event)
+	}
+	if event.Attributes["value"] != "wg0" || event.Attributes["previous"] != "eth0" {
+		t.Error("unexpected event attributes", event.Attributes)
+	}
+}
@@ -0,0 +1,318 @@
+package telemd
+
+import (
+	"github.com/edgerun/telemd/internal/telem"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// containerRuntime describes how a container runtime names the cgroups of its containers. With the systemd cgroup
+// driver, a container's cgroup is a scope called <prefix>-<container-id>.scope, with the cgroupfs driver it is a
+// directory called <prefix>-<container-id>.
+type containerRuntime struct {
+	Name   string
+	Prefix string
+}
+
+var (
+	dockerRuntime     = containerRuntime{Name: "docker", Prefix: "docker"}
+	containerdRuntime = containerRuntime{Name: "containerd", Prefix: "cri-containerd"}
+	crioRuntime       = containerRuntime{Name: "crio", Prefix: "crio"}
+	podmanRuntime     = containerRuntime{Name: "podman", Prefix: "libpod"}
+)
+
+var containerRuntimes = []containerRuntime{dockerRuntime, containerdRuntime, crioRuntime, podmanRuntime}
+
+var containerIdPattern = regexp.MustCompile("^[0-9a-f]{64}$")
+
+func init() {
+	descriptions := map[string]string{
+		"cpu":    "cpu usage time",
+		"memory": "memory usage in bytes",
+		"blkio":  "block io usage in bytes",
+		"net":    "network io usage in bytes",
+	}
+
+	for _, runtime := range []containerRuntime{containerdRuntime, crioRuntime, podmanRuntime} {
+		runtime := runtime
+
+		for _, resource := range []string{"cpu", "memory", "blkio"} {
+			resource := resource
+			RegisterInstrument(InstrumentSpec{
+				Name:        runtime.Name + "_cgrp_" + resource,
+				Description: descriptions[resource] + " of " + runtime.Name + " containers",
+				Period:      1 * time.Second,
+				New: func(env *InstrumentEnv) ContextInstrument {
+					return AdaptInstrument(newRuntimeCgroupInstrument(runtime, resource, env.Cgroup))
+				},
+			})
+		}
+
+		RegisterInstrument(InstrumentSpec{
+			Name:        runtime.Name + "_cgrp_net",
+			Description: descriptions["net"] + " of " + runtime.Name + " containers",
+			Period:      1 * time.Second,
+			New: func(env *InstrumentEnv) ContextInstrument {
+				return AdaptInstrument(newRuntimeCgroupNetworkInstrument(runtime, env.Cgroup, env.Config.Mounts.Proc))
+			},
+		})
+	}
+}
+
+func findContainerRuntime(name string) (containerRuntime, bool) {
+	for _, runtime := range containerRuntimes {
+		if runtime.Name == name {
+			return runtime, true
+		}
+	}
+	return containerRuntime{}, false
+}
+
+// parseCgroupDirName checks whether the given cgroup directory name (e.g., cri-containerd-<id>.scope) is the cgroup
+// of a container, and returns the container runtime and id if so. The cgroups of runtime monitors (e.g., the
+// libpod-conmon-<id>.scope of podman) are not considered container cgroups.
+func parseCgroupDirName(name string) (runtime containerRuntime, id string, ok bool) {
+	name = strings.TrimSuffix(name, ".scope")
+
+	for _, runtime := range containerRuntimes {
+		if !strings.HasPrefix(name, runtime.Prefix+"-") {
+			continue
+		}
+		id = strings.TrimPrefix(name, runtime.Prefix+"-")
+		if containerIdPattern.MatchString(id) {
+			return runtime, id, true
+		}
+	}
+
+	return containerRuntime{}, "", false
+}
+
+// parseCgroupContainerId extracts the container runtime and id from a line of /proc/<pid>/cgroup, e.g.:
+//
+//	11:freezer:/docker/dc65d1e5672961e7191260dec3dd532ad346719ea3ae23035e3b560867bd1183
+//	0::/system.slice/docker-dc65d1e5672961e7191260dec3dd532ad346719ea3ae23035e3b560867bd1183.scope
+//	0::/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod<uid>.slice/cri-containerd-<id>.scope
+//	4:memory:/kubepods/besteffort/podae778fdf-394c-4356-9625-ea50666783b1/<id>
+//
+// The runtime of containers in cgroupfs-managed kubepods hierarchies cannot be determined, in which case the
+// returned runtime has an empty name.
+func parseCgroupContainerId(line string) (runtime containerRuntime, id string, ok bool) {
+	parts := strings.SplitN(line, ":", 3)
+	if len(parts) != 3 {
+		return containerRuntime{}, "", false
+	}
+
+	segments := strings.Split(strings.Trim(parts[2], "/"), "/")
+
+	// the innermost container cgroup wins (e.g., for docker-in-docker)
+	for i := len(segments) - 1; i >= 0; i-- {
+		segment := segments[i]
+
+		if runtime, id, ok := parseCgroupDirName(segment); ok {
+			return runtime, id, true
+		}
+
+		if !containerIdPattern.MatchString(segment) || i == 0 {
+			continue
+		}
+
+		parent := segments[i-1]
+		if parent == "docker" {
+			return dockerRuntime, segment, true
+		}
+		if strings.HasPrefix(parent, "pod") && i >= 2 && strings.HasPrefix(segments[0], "kubepods") {
+			return containerRuntime{}, segment, true
+		}
+	}
+
+	return containerRuntime{}, "", false
+}
+
+// containerCgroup is the cgroup directory of a container found in a cgroup hierarchy.
+type containerCgroup struct {
+	Runtime containerRuntime
+	Id      string
+	Dir     string
+}
+
+// findContainerCgroups walks the given cgroup hierarchy and returns the cgroups of all containers. Only slices,
+// services, and the parent directories the cgroupfs drivers of docker, podman and the kubelet use are descended into,
+// which is where container cgroups are placed.
+func findContainerCgroups(root string) []containerCgroup {
+	var cgroups []containerCgroup
+
+	var walk func(dir string, rel string)
+	walk = func(dir string, rel string) {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			name := entry.Name()
+
+			if runtime, id, ok := parseCgroupContainerId("0::" + rel + "/" + name); ok {
+				cgroups = append(cgroups, containerCgroup{Runtime: runtime, Id: id, Dir: dir + "/" + name})
+				continue
+			}
+
+			if isContainerCgroupParent(name) {
+				walk(dir+"/"+name, rel+"/"+name)
+			}
+		}
+	}
+
+	walk(root, "")
+	return cgroups
+}
+
+func isContainerCgroupParent(name string) bool {
+	if strings.HasSuffix(name, ".slice") || strings.HasSuffix(name, ".service") {
+		return true
+	}
+	switch name {
+	case "docker", "libpod_parent", "kubepods", "besteffort", "burstable":
+		return true
+	}
+	return strings.HasPrefix(name, "pod")
+}
+
+// findRuntimeContainerDirs returns a map of container ids to the cgroup directories of the containers of the given
+// runtime found in the given cgroup hierarchy.
+func findRuntimeContainerDirs(root string, runtime containerRuntime) map[string]string {
+	dirs := make(map[string]string)
+	for _, cgroup := range findContainerCgroups(root) {
+		if cgroup.Runtime == runtime {
+			dirs[cgroup.Id] = cgroup.Dir
+		}
+	}
+	return dirs
+}
+
+// readCgroupPid returns the first pid listed in the cgroup.procs file of the given cgroup directory.
+func readCgroupPid(dir string) (string, error) {
+	pid, err := readFirstLine(dir + "/cgroup.procs")
+	if err != nil {
+		return "", err
+	}
+	if pid == "" {
+		return "", os.ErrNotExist
+	}
+	return pid, nil
+}
+
+// RuntimeCgroupInstrument reports a cgroup resource counter (cpu, memory or blkio) for each container of a container
+// runtime (e.g., containerd_cgrp_cpu/<container-id>).
+type RuntimeCgroupInstrument struct {
+	runtime  containerRuntime
+	resource string
+	root     string
+	read     func(dir string) (int64, error)
+}
+
+// RuntimeCgroupNetworkInstrument reports the network I/O of each container of a container runtime, using the network
+// statistics of a process that runs in the container's cgroup.
+type RuntimeCgroupNetworkInstrument struct {
+	runtime   containerRuntime
+	root      string
+	procMount string
+}
+
+// newRuntimeCgroupInstrument returns the instrument for the given resource, or nil if the resource is unknown.
+func newRuntimeCgroupInstrument(runtime containerRuntime, resource string, cgroupVersion string) Instrument {
+	instrument := &RuntimeCgroupInstrument{
+		runtime:  runtime,
+		resource: resource,
+		root:     "/sys/fs/cgroup",
+	}
+
+	switch resource {
+	case "cpu":
+		if cgroupVersion == "v1" {
+			instrument.root, instrument.read = "/sys/fs/cgroup/cpuacct", readCgroupCpu
+		} else {
+			instrument.read = readCgroupv2Cpu
+		}
+	case "memory":
+		if cgroupVersion == "v1" {
+			instrument.root, instrument.read = "/sys/fs/cgroup/memory", readCgroupMemory
+		} else {
+			instrument.read = readCgroupv2Memory
+		}
+	case "blkio":
+		if cgroupVersion == "v1" {
+			instrument.root, instrument.read = "/sys/fs/cgroup/blkio", readCgroupBlkio
+		} else {
+			instrument.read = readCgroupv2Blkio
+		}
+	default:
+		return nil
+	}
+
+	return instrument
+}
+
+func newRuntimeCgroupNetworkInstrument(runtime containerRuntime, cgroupVersion string, procMount string) *RuntimeCgroupNetworkInstrument {
+	root := "/sys/fs/cgroup"
+	if cgroupVersion == "v1" {
+		root = "/sys/fs/cgroup/cpuacct"
+	}
+
+	return &RuntimeCgroupNetworkInstrument{
+		runtime:   runtime,
+		root:      root,
+		procMount: procMount,
+	}
+}
+
+func (instr *RuntimeCgroupInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
+	topic := instr.runtime.Name + "_cgrp_" + instr.resource + telem.TopicSeparator
+
+	for containerId, dir := range findRuntimeContainerDirs(instr.root, instr.runtime) {
+		value, err := instr.read(dir)
+		if err != nil {
+			log.Println("error reading data file", dir, err)
+			continue
+		}
+		channel.Put(telem.NewTelemetry(topic+shortContainerId(containerId), float64(value)))
+	}
+}
+
+func (instr *RuntimeCgroupNetworkInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
+	topic := instr.runtime.Name + "_cgrp_net" + telem.TopicSeparator
+
+	for containerId, dir := range findRuntimeContainerDirs(instr.root, instr.runtime) {
+		pid, err := readCgroupPid(dir)
+		if err != nil {
+			continue // the container has no processes (yet)
+		}
+
+		rxValues, txValues, err := readTotalProcessNetworkStats(pid, instr.procMount)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Println("error parsing network stats of pid", pid, err)
+			}
+			continue
+		}
+
+		prefix := topic + shortContainerId(containerId)
+		rx := int64(0)
+		tx := int64(0)
+		for device, irx := range rxValues {
+			itx := txValues[device]
+			channel.Put(telem.NewTelemetry(prefix+"/"+device, float64(irx+itx)))
+			channel.Put(telem.NewTelemetry(prefix+"/"+device+"/rx", float64(irx)))
+			channel.Put(telem.NewTelemetry(prefix+"/"+device+"/tx", float64(itx)))
+			rx += irx
+			tx += itx
+		}
+		channel.Put(telem.NewTelemetry(prefix, float64(rx+tx)))
+	}
+}
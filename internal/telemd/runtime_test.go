@@ -0,0 +1,206 @@
+package telemd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	testId1 = "dc65d1e5672961e7191260dec3dd532ad346719ea3ae23035e3b560867bd1183"
+	testId2 = "2cc54a6877a50da0b6a2a5340dd1e8c5707a1d7d4b363e03b7cde76d2569f0c0"
+)
+
+func TestParseCgroupContainerId(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		runtime string
+		id      string
+		ok      bool
+	}{
+		{
+			name:    "docker cgroup v1",
+			line:    "11:freezer:/docker/" + testId1,
+			runtime: "docker",
+			id:      testId1,
+			ok:      true,
+		},
+		{
+			name:    "docker cgroup v2",
+			line:    "0::/system.slice/docker-" + testId1 + ".scope",
+			runtime: "docker",
+			id:      testId1,
+			ok:      true,
+		},
+		{
+			name:    "docker in docker",
+			line:    "0::/docker/" + testId1 + "/docker/" + testId2,
+			runtime: "docker",
+			id:      testId2,
+			ok:      true,
+		},
+		{
+			name:    "kubernetes cgroupfs v1",
+			line:    "10:memory:/kubepods/besteffort/podae778fdf-394c-4356-9625-ea50666783b1/" + testId2,
+			runtime: "",
+			id:      testId2,
+			ok:      true,
+		},
+		{
+			name:    "kubernetes guaranteed cgroupfs v1",
+			line:    "4:cpu,cpuacct:/kubepods/podae778fdf-394c-4356-9625-ea50666783b1/" + testId2,
+			runtime: "",
+			id:      testId2,
+			ok:      true,
+		},
+		{
+			name:    "containerd systemd v2",
+			line:    "0::/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod6226f8c8_a5fa_4684_b5c2_c758e052c22e.slice/cri-containerd-" + testId2 + ".scope",
+			runtime: "containerd",
+			id:      testId2,
+			ok:      true,
+		},
+		{
+			name:    "containerd systemd v1",
+			line:    "7:blkio:/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod6226f8c8_a5fa_4684_b5c2_c758e052c22e.slice/cri-containerd-" + testId2 + ".scope",
+			runtime: "containerd",
+			id:      testId2,
+			ok:      true,
+		},
+		{
+			name:    "cri-o systemd v2",
+			line:    "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod6226f8c8_a5fa_4684_b5c2_c758e052c22e.slice/crio-" + testId2 + ".scope",
+			runtime: "crio",
+			id:      testId2,
+			ok:      true,
+		},
+		{
+			name: "cri-o conmon",
+			line: "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod6226f8c8_a5fa_4684_b5c2_c758e052c22e.slice/crio-conmon-" + testId2 + ".scope",
+			ok:   false,
+		},
+		{
+			name:    "rootless podman",
+			line:    "0::/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-" + testId1 + ".scope",
+			runtime: "podman",
+			id:      testId1,
+			ok:      true,
+		},
+		{
+			name:    "rootless podman container payload",
+			line:    "0::/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-" + testId1 + ".scope/container",
+			runtime: "podman",
+			id:      testId1,
+			ok:      true,
+		},
+		{
+			name: "podman conmon",
+			line: "0::/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-conmon-" + testId1 + ".scope",
+			ok:   false,
+		},
+		{
+			name:    "podman cgroupfs",
+			line:    "3:memory:/libpod_parent/libpod-" + testId1,
+			runtime: "podman",
+			id:      testId1,
+			ok:      true,
+		},
+		{
+			name: "host process",
+			line: "0::/user.slice/user-1000.slice/session-2.scope",
+			ok:   false,
+		},
+		{
+			name: "systemd service",
+			line: "1:name=systemd:/system.slice/containerd.service",
+			ok:   false,
+		},
+		{
+			name: "root cgroup",
+			line: "0::/",
+			ok:   false,
+		},
+		{
+			name: "malformed line",
+			line: "garbage",
+			ok:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runtime, id, ok := parseCgroupContainerId(tt.line)
+
+			if ok != tt.ok {
+				t.Fatalf("expected ok to be %v, was %v", tt.ok, ok)
+			}
+			if runtime.Name != tt.runtime {
+				t.Errorf("expected runtime %q, was %q", tt.runtime, runtime.Name)
+			}
+			if id != tt.id {
+				t.Errorf("expected id %q, was %q", tt.id, id)
+			}
+		})
+	}
+}
+
+func TestFindRuntimeContainerDirs(t *testing.T) {
+	root, err := ioutil.TempDir("", "telemd-cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	dirs := []string{
+		"system.slice/containerd.service",
+		"system.slice/docker-" + testId1 + ".scope",
+		"kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod6226f8c8.slice/cri-containerd-" + testId2 + ".scope",
+		"kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod6226f8c8.slice/crio-" + testId1 + ".scope",
+		"user.slice/user-1000.slice/session-2.scope/crio-" + testId2 + ".scope",
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	containerd := findRuntimeContainerDirs(root, containerdRuntime)
+	if len(containerd) != 1 {
+		t.Fatal("expected one containerd container, got", containerd)
+	}
+	if dir := containerd[testId2]; dir != root+"/"+dirs[2] {
+		t.Error("unexpected containerd cgroup dir", dir)
+	}
+
+	// the crio scope in the session scope must not be found because scopes are not descended into
+	crio := findRuntimeContainerDirs(root, crioRuntime)
+	if len(crio) != 1 || crio[testId1] != root+"/"+dirs[3] {
+		t.Error("unexpected crio containers", crio)
+	}
+
+	if podman := findRuntimeContainerDirs(root, podmanRuntime); len(podman) != 0 {
+		t.Error("expected no podman containers, got", podman)
+	}
+}
+
+func TestNewRuntimeCgroupInstrument_UnknownResource(t *testing.T) {
+	if instrument := newRuntimeCgroupInstrument(dockerRuntime, "gpu", "v2"); instrument != nil {
+		t.Error("expected no instrument for an unknown resource, got", instrument)
+	}
+}
+
+func TestKubernetesContainerId(t *testing.T) {
+	systemd := "/sys/fs/cgroup/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod6226f8c8.slice/" +
+		"cri-containerd-" + testId1 + ".scope"
+	if id := kubernetesContainerId(systemd); id != testId1 {
+		t.Error("unexpected id of the systemd cgroup", id)
+	}
+
+	// with the cgroupfs driver, the directory is named after the container id
+	cgroupfs := "/sys/fs/cgroup/kubepods/besteffort/pod6226f8c8/" + testId2
+	if id := kubernetesContainerId(cgroupfs); id != testId2 {
+		t.Error("unexpected id of the cgroupfs cgroup", id)
+	}
+}
@@ -0,0 +1,219 @@
+package telemd
+
+import (
+	"errors"
+	"github.com/edgerun/telemd/internal/telem"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// clockTicks is the USER_HZ the kernel uses for process times in /proc/<pid>/stat. It is 100 on all architectures
+// telemd runs on.
+const clockTicks = 100
+
+func init() {
+	RegisterInstrument(InstrumentSpec{
+		Name:        "telemd",
+		Description: "self-telemetry of the telemd process",
+		Period:      5 * time.Second,
+		New: func(env *InstrumentEnv) ContextInstrument {
+			return AdaptInstrument(NewSelfInstrument(env.Self))
+		},
+	})
+}
+
+// SelfStats counts what the daemon itself does, i.e., the messages it publishes and drops per sink, the redis
+// reconnects, and the telemetry that is waiting to be reported.
+type SelfStats struct {
+	// accessed atomically, first in the struct to be 64-bit aligned on 32-bit platforms
+	reconnects uint64
+	rejected   uint64
+	backlog    int64
+
+	mutex     sync.Mutex
+	published map[string]uint64
+	dropped   map[string]uint64
+}
+
+func NewSelfStats() *SelfStats {
+	return &SelfStats{
+		published: make(map[string]uint64),
+		dropped:   make(map[string]uint64),
+	}
+}
+
+// Published counts a message that was published by the given sink.
+func (stats *SelfStats) Published(sink string) {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	stats.published[sink]++
+}
+
+// Dropped counts a message that the given sink could not publish.
+func (stats *SelfStats) Dropped(sink string) {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	stats.dropped[sink]++
+}
+
+// Reconnected counts a recovered redis connection.
+func (stats *SelfStats) Reconnected() {
+	atomic.AddUint64(&stats.reconnects, 1)
+}
+
+// RejectedCommand counts a command that was rejected because it was not signed correctly.
+func (stats *SelfStats) RejectedCommand() {
+	atomic.AddUint64(&stats.rejected, 1)
+}
+
+// Backlog returns the number of telemetry values that instruments are currently waiting to put into the channel.
+func (stats *SelfStats) Backlog() int64 {
+	return atomic.LoadInt64(&stats.backlog)
+}
+
+// sinks returns a copy of the published and dropped counters by sink.
+func (stats *SelfStats) sinks() (published map[string]uint64, dropped map[string]uint64) {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	published = make(map[string]uint64, len(stats.published))
+	for sink, count := range stats.published {
+		published[sink] = count
+	}
+	dropped = make(map[string]uint64, len(stats.dropped))
+	for sink, count := range stats.dropped {
+		dropped[sink] = count
+	}
+	return published, dropped
+}
+
+// trackBacklog returns a TelemetryChannel that counts the values that are waiting to be put into the given channel,
+// and the values that were dropped on the way to the given sink because of a deadline or the shutdown.
+func (stats *SelfStats) trackBacklog(channel telem.TelemetryChannel, sink string) telem.TelemetryChannel {
+	return &backlogChannel{channel, stats, sink}
+}
+
+type backlogChannel struct {
+	telem.TelemetryChannel
+	stats *SelfStats
+	sink  string
+}
+
+func (b *backlogChannel) Put(telemetry telem.Telemetry) {
+	atomic.AddInt64(&b.stats.backlog, 1)
+	defer atomic.AddInt64(&b.stats.backlog, -1)
+	b.TelemetryChannel.Put(telemetry)
+}
+
+func (b *backlogChannel) putOrDone(done <-chan struct{}, telemetry telem.Telemetry) bool {
+	atomic.AddInt64(&b.stats.backlog, 1)
+	defer atomic.AddInt64(&b.stats.backlog, -1)
+
+	if !putOrDone(b.TelemetryChannel, done, telemetry) {
+		b.stats.Dropped(b.sink)
+		return false
+	}
+	return true
+}
+
+// SelfInstrument reports the resource usage and internals of the telemd process into the topics telemd/...
+type SelfInstrument struct {
+	stats *SelfStats
+	rates *counterRates
+
+	gcSampled bool
+	gcPauseNs uint64
+}
+
+func NewSelfInstrument(stats *SelfStats) Instrument {
+	return &SelfInstrument{stats: stats, rates: newCounterRates()}
+}
+
+func (instr *SelfInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
+	prefix := "telemd" + telem.TopicSeparator
+
+	if utime, stime, err := readProcessTimes("/proc/self/stat"); err == nil {
+		if rates, ok := instr.rates.update("cpu", time.Now(), utime+stime); ok {
+			channel.Put(telem.NewTelemetry(prefix+"cpu", rates[0]*100/clockTicks))
+		}
+	}
+
+	if rss, err := readProcessRss("/proc/self/statm"); err == nil {
+		channel.Put(telem.NewTelemetry(prefix+"rss", float64(rss)/1000))
+	}
+
+	channel.Put(telem.NewTelemetry(prefix+"goroutines", float64(runtime.NumGoroutine())))
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	if instr.gcSampled {
+		// the total time the garbage collector stopped the world since the previous measurement
+		pause := time.Duration(mem.PauseTotalNs - instr.gcPauseNs)
+		channel.Put(telem.NewTelemetry(prefix+"gc_pause", pause.Seconds()))
+	}
+	instr.gcSampled = true
+	instr.gcPauseNs = mem.PauseTotalNs
+
+	published, dropped := instr.stats.sinks()
+	for sink, count := range published {
+		channel.Put(telem.NewTelemetry(prefix+"published"+telem.TopicSeparator+sink, float64(count)))
+	}
+	for sink, count := range dropped {
+		channel.Put(telem.NewTelemetry(prefix+"dropped"+telem.TopicSeparator+sink, float64(count)))
+	}
+
+	channel.Put(telem.NewTelemetry(prefix+"redis_reconnects", float64(atomic.LoadUint64(&instr.stats.reconnects))))
+	channel.Put(telem.NewTelemetry(prefix+"commands_rejected", float64(atomic.LoadUint64(&instr.stats.rejected))))
+	channel.Put(telem.NewTelemetry(prefix+"backlog", float64(instr.stats.Backlog())))
+}
+
+// readProcessTimes returns the user and system time in clock ticks from the given /proc/<pid>/stat file.
+func readProcessTimes(path string) (utime int64, stime int64, err error) {
+	line, err := readFirstLine(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// the command name may contain spaces, so the fields are counted from its closing parenthesis
+	i := strings.LastIndex(line, ")")
+	if i < 0 {
+		return 0, 0, errors.New("malformed stat line: " + line)
+	}
+	fields := strings.Fields(line[i+1:])
+	if len(fields) < 13 {
+		return 0, 0, errors.New("malformed stat line: " + line)
+	}
+
+	// fields 14 (utime) and 15 (stime) of the stat line
+	if utime, err = strconv.ParseInt(fields[11], 10, 64); err != nil {
+		return 0, 0, err
+	}
+	if stime, err = strconv.ParseInt(fields[12], 10, 64); err != nil {
+		return 0, 0, err
+	}
+	return utime, stime, nil
+}
+
+// readProcessRss returns the resident set size in bytes from the given /proc/<pid>/statm file.
+func readProcessRss(path string) (int64, error) {
+	line, err := readFirstLine(path)
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return 0, errors.New("malformed statm line: " + line)
+	}
+
+	pages, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return pages * int64(os.Getpagesize()), nil
+}
@@ -0,0 +1,59 @@
+package telemd
+
+import (
+	"github.com/edgerun/telemd/internal/telem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadProcessTimes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telemd-self")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "stat")
+	stat := "4711 (tele md) S 1 4711 4711 0 -1 4194560 1234 0 0 0 152 38 0 0 20 0 12 0 5000 800000000 2500 " +
+		"18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 2 0 0 0 0 0"
+	if err := ioutil.WriteFile(path, []byte(stat), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	utime, stime, err := readProcessTimes(path)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if utime != 152 || stime != 38 {
+		t.Error("expected utime 152 and stime 38, got", utime, stime)
+	}
+}
+
+func TestReadProcessRss(t *testing.T) {
+	rss, err := readProcessRss("/proc/self/statm")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if rss <= 0 {
+		t.Error("expected some resident memory, got", rss)
+	}
+}
+
+func TestSelfStats_CountsDroppedValues(t *testing.T) {
+	stats := NewSelfStats()
+	channel := stats.trackBacklog(telem.NewTelemetryChannel(), "redis")
+
+	// nobody reads the channel, so the value is dropped once the deadline has passed
+	newDeadlineChannel(10*time.Millisecond, channel).Put(telem.NewTelemetry("cpu", 42))
+
+	_, dropped := stats.sinks()
+	if dropped["redis"] != 1 {
+		t.Error("expected one dropped value, got", dropped)
+	}
+	if stats.Backlog() != 0 {
+		t.Error("expected no backlog, got", stats.Backlog())
+	}
+}
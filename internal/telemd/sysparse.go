@@ -47,23 +47,30 @@ func readBlockDeviceStats(dev string) ([]int64, error) {
 
 // readCpuUtil returns an array of the following values from /proc/stat
 // user, nice, system, idle, iowait, irq, softirq
-func readCpuUtil() []float64 {
+func readCpuUtil() ([]float64, error) {
 	line, err := readFirstLine("/proc/stat")
-	check(err)
+	if err != nil {
+		return nil, err
+	}
 	line = strings.Trim(line, " ")
 	parts := strings.Split(line, " ")
+	if len(parts) < 6 {
+		return nil, errors.New("unexpected /proc/stat format: " + line)
+	}
 
 	var values []float64
 	for _, v := range parts[2:] { // first two parts are 'cpu' and a whitespace
 		val, err := strconv.ParseFloat(v, 64)
-		check(err)
+		if err != nil {
+			return nil, err
+		}
 		values = append(values, val)
 	}
 
-	return values
+	return values, nil
 }
 
-func readMeminfo() map[string]string {
+func readMeminfo() (map[string]string, error) {
 	vals := make(map[string]string)
 
 	parser := func(line string) bool {
@@ -79,9 +86,11 @@ func readMeminfo() map[string]string {
 		return true
 	}
 
-	check(visitLines("/proc/meminfo", parser))
+	if err := visitLines("/proc/meminfo", parser); err != nil {
+		return nil, err
+	}
 
-	return vals
+	return vals, nil
 }
 
 type PsiMeasure struct {
@@ -119,15 +128,6 @@ func readPsiMeasure(line string) *PsiMeasure {
 	}
 }
 
-func parseIw(device string, attribute string, index int) (string, error) {
-	args := "iw dev " + device + " link | awk -F '[ ]' '/" + attribute + ":/{print $" + strconv.Itoa(index) + "}'"
-	result, err := execCommand(args)
-	if err != nil {
-		return result, err
-	}
-	return result, nil
-}
-
 func readPsiResult(resource string) (*PsiResult, error) {
 	path := "/proc/pressure/" + resource
 
@@ -152,7 +152,11 @@ func readPsiResult(resource string) (*PsiResult, error) {
 }
 
 func readMemTotal() (int64, error) {
-	val, ok := readMeminfo()["MemTotal"]
+	meminfo, err := readMeminfo()
+	if err != nil {
+		return 0, err
+	}
+	val, ok := meminfo["MemTotal"]
 	if !ok {
 		return 0, errors.New("MemTotal not found")
 	}
@@ -247,8 +251,7 @@ func allPids(procFolder string) ([]string, error) {
 }
 
 func getContainerId(pid string, procMount string) (string, error) {
-	// gets content of /proc/<pid>/cgroup
-	// in cgroup v1 is list of multiple lines -> look for first that contains 'docker' substring
+	// gets content of /proc/<pid>/cgroup and returns the id of the first container cgroup found, e.g.:
 	// 11:freezer:/docker/dc65d1e5672961e7191260dec3dd532ad346719ea3ae23035e3b560867bd1183
 	file, err := os.Open(procMount + "/" + pid + "/cgroup")
 	if err != nil {
@@ -259,30 +262,8 @@ func getContainerId(pid string, procMount string) (string, error) {
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "docker") {
-			split := strings.Split(line, "/")[2]
-			prefix := "docker-"
-			suffix := ".scope"
-
-			if strings.HasPrefix(split, prefix) {
-				// cgroup v2
-				return strings.TrimSuffix(strings.TrimPrefix(split, prefix), suffix), nil
-			} else {
-				// cgroup v1
-				return split, nil
-			}
-		} else if strings.Contains(line, "kubepods") && !strings.Contains(line, "cri-containerd") {
-			// kubernetes
-			// freezer:/kubepods/besteffort/podae778fdf-394c-4356-9625-ea50666783b1/2cc54a6877a50da0b6a2a5340dd1e8c5707a1d7d4b363e03b7cde76d2569f0c0
-			return strings.Split(line, "/")[4], nil
-		} else {
-			r, _ := regexp.Compile("[0-9a-zA-Z]{32}")
-			containerId := r.FindString(line)
-			if len(containerId) > 0 {
-				return containerId, nil
-			}
-
+		if _, containerId, ok := parseCgroupContainerId(scanner.Text()); ok {
+			return containerId, nil
 		}
 	}
 	return "", errors.New("Did not find container for PID " + pid)
@@ -3,7 +3,10 @@ package telemd
 import "testing"
 
 func TestReadMeminfo(t *testing.T) {
-	meminfo := readMeminfo()
+	meminfo, err := readMeminfo()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
 
 	if _, ok := meminfo["MemTotal"]; !ok {
 		t.Error("Expected meminfo to have a key 'MemTotal'")
@@ -0,0 +1,97 @@
+package telemd
+
+import (
+	"context"
+	"fmt"
+	"github.com/edgerun/telemd/internal/telem"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterInstrument(InstrumentSpec{
+		Name:        "textfile",
+		Description: "metrics of the *.prom files in the textfile directory",
+		Period:      10 * time.Second,
+		Options: []InstrumentOption{
+			{"telemd_textfile_dir", "/var/lib/telemd/textfile", "directory of the *.prom files, empty to disable"},
+			{"telemd_textfile_max_age", "5m", "files not modified within this duration are stale"},
+		},
+		New: func(env *InstrumentEnv) ContextInstrument {
+			textfile := env.Config.Instruments.Textfile
+			if textfile.Dir == "" {
+				return nil
+			}
+			return TextfileInstrument{Dir: textfile.Dir, MaxAge: textfile.MaxAge}
+		},
+	})
+}
+
+// TextfileInstrument republishes the metrics found in the *.prom files of a directory, so that cron jobs and other
+// agents can report telemetry by writing a file. The files are parsed as described in parseMetrics. Files that were
+// not modified within MaxAge are considered stale, and their values are not reported anymore. For each file, the
+// instrument reports the seconds since its last modification into telemd/textfile/age/<file>.
+type TextfileInstrument struct {
+	Dir    string
+	MaxAge time.Duration
+}
+
+func (instr TextfileInstrument) Measure(ctx context.Context, channel telem.TelemetryChannel) error {
+	files, err := filepath.Glob(filepath.Join(instr.Dir, "*.prom"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	var errs []string
+	now := time.Now()
+
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		name := strings.TrimSuffix(filepath.Base(file), ".prom")
+
+		age, samples, err := readTextfile(file, now)
+		if os.IsNotExist(err) {
+			continue // removed in the meantime
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", filepath.Base(file), err))
+		}
+
+		channel.Put(telem.NewTelemetry("telemd"+telem.TopicSeparator+"textfile"+telem.TopicSeparator+"age"+
+			telem.TopicSeparator+name, age.Seconds()))
+
+		if instr.MaxAge > 0 && age > instr.MaxAge {
+			continue
+		}
+		reportMetrics(channel, samples)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("malformed textfiles: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// readTextfile returns the time since the file was last modified, and the metrics it contains.
+func readTextfile(path string, now time.Time) (time.Duration, []metricSample, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	samples, err := parseMetrics(file)
+	return now.Sub(info.ModTime()), samples, err
+}
@@ -0,0 +1,97 @@
+package telemd
+
+import (
+	"context"
+	"github.com/edgerun/telemd/internal/telem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func collectTextfile(t *testing.T, instrument TextfileInstrument) (map[string]float64, error) {
+	tc := telem.NewTelemetryChannel()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- instrument.Measure(context.Background(), tc)
+	}()
+
+	values := make(map[string]float64)
+	for {
+		select {
+		case telemetry := <-tc.Channel():
+			values[telemetry.Topic] = telemetry.Value
+		case err := <-errs:
+			return values, err
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timeout while measuring textfile instrument")
+		}
+	}
+}
+
+func TestTextfileInstrument_Measure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telemd-textfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_ = ioutil.WriteFile(filepath.Join(dir, "backup.prom"), []byte("backup_size 42\n"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("ignored 1\n"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "stale.prom"), []byte("stale 1\n"), 0644)
+
+	old := time.Now().Add(-time.Hour)
+	_ = os.Chtimes(filepath.Join(dir, "stale.prom"), old, old)
+
+	values, err := collectTextfile(t, TextfileInstrument{Dir: dir, MaxAge: time.Minute})
+	if err != nil {
+		t.Error("Unexpected error", err)
+	}
+
+	if values["backup_size"] != 42 {
+		t.Error("Expected backup_size to be reported, got", values)
+	}
+	if _, ok := values["ignored"]; ok {
+		t.Error("Expected files without .prom suffix to be ignored")
+	}
+	if _, ok := values["stale"]; ok {
+		t.Error("Expected values of stale files not to be reported")
+	}
+	if age, ok := values["telemd/textfile/age/stale"]; !ok || age < 3500 {
+		t.Error("Expected the age of the stale file to be reported, got", age)
+	}
+	if _, ok := values["telemd/textfile/age/backup"]; !ok {
+		t.Error("Expected the age of the backup file to be reported")
+	}
+}
+
+func TestTextfileInstrument_MeasureMalformed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telemd-textfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_ = ioutil.WriteFile(filepath.Join(dir, "broken.prom"), []byte("valid 1\nbroken\n"), 0644)
+
+	values, err := collectTextfile(t, TextfileInstrument{Dir: dir, MaxAge: time.Minute})
+	if err == nil {
+		t.Error("Expected an error for a malformed file")
+	}
+	if values["valid"] != 1 {
+		t.Error("Expected the valid lines to be reported, got", values)
+	}
+}
+
+func TestTextfileInstrument_MeasureMissingDir(t *testing.T) {
+	values, err := collectTextfile(t, TextfileInstrument{Dir: "/does/not/exist", MaxAge: time.Minute})
+
+	if err != nil {
+		t.Error("Unexpected error", err)
+	}
+	if len(values) != 0 {
+		t.Error("Expected no values, got", values)
+	}
+}
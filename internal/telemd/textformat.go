@@ -0,0 +1,176 @@
+package telemd
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/edgerun/telemd/internal/telem"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricSample is a single value parsed from the text format of custom metrics.
+type metricSample struct {
+	Topic string
+	Value float64
+	// Time is the time of the sample if the input contained a timestamp, or the zero time otherwise
+	Time time.Time
+}
+
+// parseMetrics parses custom metrics in either of the following line-based formats, which may be mixed:
+//
+//   - simple: `metric[/subsystem] value`, e.g., `queue_depth/orders 17`
+//   - Prometheus text: `metric{label="value",...} value [timestamp]`, where the label values become subsystems of
+//     the topic in the order of the sorted label names, e.g., `queue_depth{queue="orders"} 17` becomes
+//     `queue_depth/orders`
+//
+// Empty lines and comments starting with `#` are ignored, as are NaN and infinite values. Malformed lines are
+// skipped; the returned error describes the first one.
+func parseMetrics(r io.Reader) ([]metricSample, error) {
+	var samples []metricSample
+	var firstErr error
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		sample, err := parseMetricLine(line)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			continue
+		}
+		if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+
+		samples = append(samples, sample)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return samples, err
+	}
+	return samples, firstErr
+}
+
+func parseMetricLine(line string) (metricSample, error) {
+	var sample metricSample
+
+	topic, rest, err := splitMetricName(line)
+	if err != nil {
+		return sample, err
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) < 1 || len(fields) > 2 {
+		return sample, fmt.Errorf("expected a value and an optional timestamp: %s", line)
+	}
+
+	sample.Topic = topic
+	sample.Value, err = strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, fmt.Errorf("invalid value %s", fields[0])
+	}
+
+	if len(fields) == 2 {
+		// prometheus timestamps are milliseconds since epoch
+		ms, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return sample, fmt.Errorf("invalid timestamp %s", fields[1])
+		}
+		sample.Time = time.Unix(0, ms*int64(time.Millisecond))
+	}
+
+	return sample, nil
+}
+
+// splitMetricName returns the topic of the metric (including subsystems built from labels) and the rest of the line.
+func splitMetricName(line string) (string, string, error) {
+	i := strings.IndexAny(line, "{ \t")
+	if i <= 0 {
+		return "", "", fmt.Errorf("expected a metric name and a value: %s", line)
+	}
+
+	name := line[:i]
+	if line[i] != '{' {
+		return name, line[i:], nil
+	}
+
+	end := strings.LastIndex(line, "}")
+	if end < i {
+		return "", "", fmt.Errorf("unterminated labels: %s", line)
+	}
+
+	labels, err := parseLabels(line[i+1 : end])
+	if err != nil {
+		return "", "", err
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	topic := name
+	for _, key := range keys {
+		topic += telem.TopicSeparator + labels[key]
+	}
+
+	return topic, line[end+1:], nil
+}
+
+// parseLabels parses the label pairs of a prometheus metric, e.g., `queue="orders",host="a"`.
+func parseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+
+	for {
+		s = strings.TrimLeft(s, " ,")
+		if s == "" {
+			return labels, nil
+		}
+
+		eq := strings.Index(s, "=")
+		if eq <= 0 || len(s) < eq+2 || s[eq+1] != '"' {
+			return nil, fmt.Errorf("invalid label: %s", s)
+		}
+		key := strings.TrimSpace(s[:eq])
+
+		// find the closing quote, respecting escaped quotes
+		value := strings.Builder{}
+		j := eq + 2
+		for ; j < len(s); j++ {
+			if s[j] == '\\' && j+1 < len(s) {
+				j++
+				switch s[j] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[j])
+				}
+				continue
+			}
+			if s[j] == '"' {
+				break
+			}
+			value.WriteByte(s[j])
+		}
+		if j >= len(s) {
+			return nil, fmt.Errorf("unterminated label value: %s", s)
+		}
+
+		labels[key] = value.String()
+		s = s[j+1:]
+	}
+}
@@ -0,0 +1,89 @@
+package telemd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMetrics_Simple(t *testing.T) {
+	samples, err := parseMetrics(strings.NewReader("queue_depth 17\n\nqueue_depth/orders 4.5\n"))
+
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if len(samples) != 2 {
+		t.Fatal("Expected 2 samples, got", len(samples))
+	}
+	if samples[0].Topic != "queue_depth" || samples[0].Value != 17 {
+		t.Error("Unexpected sample", samples[0])
+	}
+	if samples[1].Topic != "queue_depth/orders" || samples[1].Value != 4.5 {
+		t.Error("Unexpected sample", samples[1])
+	}
+	if !samples[0].Time.IsZero() {
+		t.Error("Expected no time for a sample without timestamp")
+	}
+}
+
+func TestParseMetrics_Prometheus(t *testing.T) {
+	text := `# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{code="400",method="post"} 3
+temperature{sensor="a \"b\""} 21.5
+up 1
+`
+	samples, err := parseMetrics(strings.NewReader(text))
+
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if len(samples) != 4 {
+		t.Fatal("Expected 4 samples, got", len(samples))
+	}
+
+	if samples[0].Topic != "http_requests_total/200/post" {
+		t.Error("Expected label values in the order of label names, got", samples[0].Topic)
+	}
+	if samples[0].Value != 1027 {
+		t.Error("Unexpected value", samples[0].Value)
+	}
+	if !samples[0].Time.Equal(time.Unix(1395066363, 0)) {
+		t.Error("Unexpected time", samples[0].Time)
+	}
+	if samples[1].Topic != "http_requests_total/400/post" {
+		t.Error("Unexpected topic", samples[1].Topic)
+	}
+	if samples[2].Topic != `temperature/a "b"` {
+		t.Error("Unexpected topic", samples[2].Topic)
+	}
+	if samples[3].Topic != "up" || samples[3].Value != 1 {
+		t.Error("Unexpected sample", samples[3])
+	}
+}
+
+func TestParseMetrics_SkipsNaN(t *testing.T) {
+	samples, err := parseMetrics(strings.NewReader("a NaN\nb +Inf\nc 1\n"))
+
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if len(samples) != 1 || samples[0].Topic != "c" {
+		t.Error("Expected only the finite sample, got", samples)
+	}
+}
+
+func TestParseMetrics_Malformed(t *testing.T) {
+	samples, err := parseMetrics(strings.NewReader("a 1\nb\nc foo\nd{x=\"1\" 2\ne 5\n"))
+
+	if err == nil {
+		t.Error("Expected an error for malformed lines")
+	} else if !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Error("Expected the error to describe the first malformed line, got", err)
+	}
+
+	if len(samples) != 2 || samples[0].Topic != "a" || samples[1].Topic != "e" {
+		t.Error("Expected the valid lines to be parsed, got", samples)
+	}
+}
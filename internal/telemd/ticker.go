@@ -1,38 +1,66 @@
 package telemd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/edgerun/telemd/internal/telem"
+	"sync"
 	"time"
 )
 
 type TelemetryTicker interface {
 	Run()
 	Stop()
+	// Pause and Unpause can be called repeatedly, pausing a paused ticker has no effect
 	Pause()
 	Unpause()
+	// SetPeriod changes the period of a running ticker
+	SetPeriod(period time.Duration)
+	Stats() InstrumentStats
 }
 
 type telemetryTicker struct {
-	instrument Instrument
+	instrument ContextInstrument
 	telemetryC telem.TelemetryChannel
 	done       chan bool
 	pause      chan bool
+	period     chan time.Duration
 	duration   time.Duration
-	ticker     *time.Ticker
+	timeout    time.Duration
+
+	mutex    sync.Mutex
+	stats    InstrumentStats
+	timedOut bool // whether the timeout of the running measurement was already counted
 }
 
-func NewTelemetryTicker(instrument Instrument, channel telem.TelemetryChannel, duration time.Duration) TelemetryTicker {
+// NewTelemetryTicker creates a ticker that measures the instrument every period. A measurement that takes longer than
+// the given timeout is recorded as failure, and telemetry it reports after the deadline is dropped.
+func NewTelemetryTicker(instrument ContextInstrument, channel telem.TelemetryChannel, duration time.Duration, timeout time.Duration) TelemetryTicker {
 	return &telemetryTicker{
 		instrument: instrument,
 		telemetryC: channel,
 		done:       make(chan bool),
 		pause:      make(chan bool),
+		period:     make(chan time.Duration),
 		duration:   duration,
+		timeout:    timeout,
+		stats:      InstrumentStats{Period: duration},
 	}
 }
 
 func (ticker *telemetryTicker) Run() {
-	ticker.ticker = time.NewTicker(ticker.duration)
+	clock := time.NewTicker(ticker.duration)
+	defer func() {
+		clock.Stop()
+	}()
+	tick := clock.C
+
+	// a single goroutine runs the measurements, so an instrument is never called concurrently and can keep state
+	// between two ticks. the control loop stays responsive while a measurement is blocked.
+	ticks := make(chan struct{})
+	defer close(ticks)
+	go ticker.measure(ticks)
 
 	for {
 		select {
@@ -42,39 +70,186 @@ func (ticker *telemetryTicker) Run() {
 				return
 			}
 		case pause := <-ticker.pause:
-			if pause {
-			Pausing:
-				for {
-					select {
-					case done := <-ticker.done:
-						if done {
-							// we received done while pausing
-							return
-						}
-					case pause := <-ticker.pause:
-						if !pause {
-							break Pausing
-						}
-					}
-				}
+			paused := tick == nil
+			if pause && !paused {
+				clock.Stop()
+				tick = nil
+			} else if !pause && paused {
+				clock = time.NewTicker(ticker.duration)
+				tick = clock.C
+			}
+			ticker.setStats(func(stats *InstrumentStats) {
+				stats.Paused = pause
+			})
+		case period := <-ticker.period:
+			ticker.duration = period
+			if tick != nil {
+				clock.Stop()
+				clock = time.NewTicker(period)
+				tick = clock.C
+			}
+			ticker.setStats(func(stats *InstrumentStats) {
+				stats.Period = period
+			})
+		case <-tick:
+			select {
+			case ticks <- struct{}{}:
+			default:
+				// the previous measurement is still running, skip this tick
+				ticker.setStats(func(stats *InstrumentStats) {
+					stats.Skipped++
+				})
 			}
-		case <-ticker.ticker.C:
-			go ticker.instrument.MeasureAndReport(ticker.telemetryC)
 		}
 	}
 }
 
+func (ticker *telemetryTicker) setStats(update func(stats *InstrumentStats)) {
+	ticker.mutex.Lock()
+	defer ticker.mutex.Unlock()
+	update(&ticker.stats)
+}
+
+func (ticker *telemetryTicker) measure(ticks <-chan struct{}) {
+	for range ticks {
+		ticker.measureOnce()
+	}
+}
+
+func (ticker *telemetryTicker) measureOnce() {
+	ctx, cancel := context.WithTimeout(context.Background(), ticker.timeout)
+	defer cancel()
+
+	start := time.Now()
+	ticker.mutex.Lock()
+	ticker.stats.Running = true
+	ticker.stats.LastRun = start
+	ticker.timedOut = false
+	ticker.mutex.Unlock()
+
+	err := measureSafely(ctx, ticker.instrument, newDeadlineChannel(ticker.timeout, ticker.telemetryC))
+
+	duration := time.Since(start)
+
+	ticker.mutex.Lock()
+	defer ticker.mutex.Unlock()
+
+	ticker.stats.Running = false
+	ticker.stats.Runs++
+	ticker.stats.LastDuration = duration
+
+	if ctx.Err() == context.DeadlineExceeded {
+		if ticker.timedOut {
+			return // already counted by Stats while the measurement was running
+		}
+		ticker.stats.Timeouts++
+		err = errors.New("measurement exceeded deadline of " + ticker.timeout.String())
+	}
+	if err != nil {
+		ticker.stats.Failures++
+		ticker.stats.LastError = err.Error()
+		ticker.stats.LastErrorTime = time.Now()
+	}
+}
+
+// measureSafely runs the measurement and returns a panic of the instrument as error, so that a single broken
+// instrument cannot take down the daemon.
+func measureSafely(ctx context.Context, instrument ContextInstrument, channel telem.TelemetryChannel) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("instrument panicked: %v", r)
+		}
+	}()
+
+	return instrument.Measure(ctx, channel)
+}
+
+// Stats returns a snapshot of the ticker's instrument statistics. A measurement that is still running after its
+// deadline is counted as timeout right away, as instruments that ignore the context (e.g., adapted instruments) cannot
+// be interrupted and may never return.
+func (ticker *telemetryTicker) Stats() InstrumentStats {
+	ticker.mutex.Lock()
+	defer ticker.mutex.Unlock()
+
+	if ticker.stats.Running && !ticker.timedOut && time.Since(ticker.stats.LastRun) > ticker.timeout {
+		ticker.timedOut = true
+		ticker.stats.Timeouts++
+		ticker.stats.Failures++
+		ticker.stats.LastError = "measurement exceeded deadline of " + ticker.timeout.String()
+		ticker.stats.LastErrorTime = time.Now()
+	}
+
+	return ticker.stats
+}
+
 func (ticker *telemetryTicker) Pause() {
 	ticker.pause <- true
-	ticker.ticker.Stop()
 }
 
 func (ticker *telemetryTicker) Unpause() {
-	ticker.ticker = time.NewTicker(ticker.duration)
 	ticker.pause <- false
 }
 
+func (ticker *telemetryTicker) SetPeriod(period time.Duration) {
+	ticker.period <- period
+}
+
 func (ticker *telemetryTicker) Stop() {
 	ticker.done <- true
-	ticker.ticker.Stop()
+}
+
+// deadlineChannel is a TelemetryChannel that drops telemetry once done is closed, so that a measurement that
+// exceeded its deadline, or a server that is shutting down, cannot block on a congested channel.
+type deadlineChannel struct {
+	done    <-chan struct{}
+	channel telem.TelemetryChannel
+}
+
+// newDeadlineChannel returns a TelemetryChannel that drops telemetry once the timeout has passed. It stays open until
+// then even if the measurement has returned, as some instruments report from goroutines that outlive the measurement.
+func newDeadlineChannel(timeout time.Duration, channel telem.TelemetryChannel) telem.TelemetryChannel {
+	done := make(chan struct{})
+	time.AfterFunc(timeout, func() {
+		close(done)
+	})
+	return &deadlineChannel{done: done, channel: channel}
+}
+
+// newShutdownChannel returns a TelemetryChannel that drops telemetry once done is closed.
+func newShutdownChannel(done <-chan struct{}, channel telem.TelemetryChannel) telem.TelemetryChannel {
+	return &deadlineChannel{done: done, channel: channel}
+}
+
+func (d *deadlineChannel) Channel() chan telem.Telemetry {
+	return d.channel.Channel()
+}
+
+func (d *deadlineChannel) Put(telemetry telem.Telemetry) {
+	putOrDone(d.channel, d.done, telemetry)
+}
+
+func (d *deadlineChannel) Close() {
+	// the underlying channel is owned by the daemon
+}
+
+// doneAwareChannel is a TelemetryChannel that wraps another one, e.g., to cache or count the values, and can abandon a
+// put once done is closed.
+type doneAwareChannel interface {
+	telem.TelemetryChannel
+	putOrDone(done <-chan struct{}, telemetry telem.Telemetry) bool
+}
+
+// putOrDone puts the telemetry into the channel, unless done is closed first. It returns whether the telemetry was put.
+// Channels that wrap another one implement doneAwareChannel, so that the values still pass through all wrappers.
+func putOrDone(channel telem.TelemetryChannel, done <-chan struct{}, telemetry telem.Telemetry) bool {
+	if c, ok := channel.(doneAwareChannel); ok {
+		return c.putOrDone(done, telemetry)
+	}
+
+	select {
+	case <-done:
+		return false
+	case channel.Channel() <- telemetry:
+		return true
+	}
 }
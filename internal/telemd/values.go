@@ -0,0 +1,72 @@
+package telemd
+
+import (
+	"github.com/edgerun/telemd/internal/telem"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LastValueCache keeps the latest value of every topic that was put into the telemetry channel, so that consumers
+// that join late can get a snapshot of all current values rather than waiting a full period for every topic.
+type LastValueCache struct {
+	mutex  sync.RWMutex
+	values map[string]telem.Telemetry
+}
+
+func NewLastValueCache() *LastValueCache {
+	return &LastValueCache{values: make(map[string]telem.Telemetry)}
+}
+
+// Track returns a TelemetryChannel that records every value in the cache before putting it into the given channel.
+func (cache *LastValueCache) Track(channel telem.TelemetryChannel) telem.TelemetryChannel {
+	return &cachingChannel{channel, cache}
+}
+
+func (cache *LastValueCache) put(telemetry telem.Telemetry) {
+	if telemetry == telem.EmptyTelemetry {
+		return
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.values[telemetry.Topic] = telemetry
+}
+
+// Snapshot returns the latest value of each topic ordered by topic. Values that were not updated within maxAge are
+// removed from the cache, e.g., the ones of a container that has stopped.
+func (cache *LastValueCache) Snapshot(maxAge time.Duration) []telem.Telemetry {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	threshold := time.Now().Add(-maxAge)
+	values := make([]telem.Telemetry, 0, len(cache.values))
+
+	for topic, telemetry := range cache.values {
+		if telemetry.Time.Before(threshold) {
+			delete(cache.values, topic)
+			continue
+		}
+		values = append(values, telemetry)
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Topic < values[j].Topic
+	})
+	return values
+}
+
+type cachingChannel struct {
+	telem.TelemetryChannel
+	cache *LastValueCache
+}
+
+func (c *cachingChannel) Put(telemetry telem.Telemetry) {
+	c.cache.put(telemetry)
+	c.TelemetryChannel.Put(telemetry)
+}
+
+func (c *cachingChannel) putOrDone(done <-chan struct{}, telemetry telem.Telemetry) bool {
+	c.cache.put(telemetry)
+	return putOrDone(c.TelemetryChannel, done, telemetry)
+}
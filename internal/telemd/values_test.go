@@ -0,0 +1,86 @@
+package telemd
+
+import (
+	"github.com/edgerun/telemd/internal/telem"
+	"testing"
+	"time"
+)
+
+func TestLastValueCache_Track(t *testing.T) {
+	cache := NewLastValueCache()
+	tc := telem.NewTelemetryChannel()
+	channel := cache.Track(tc)
+
+	go func() {
+		channel.Put(telem.NewTelemetry("cpu", 10))
+		channel.Put(telem.NewTelemetry("ram", 2048))
+		channel.Put(telem.NewTelemetry("cpu", 20))
+	}()
+
+	// the values are still put into the underlying channel
+	for i := 0; i < 3; i++ {
+		<-tc.Channel()
+	}
+
+	values := cache.Snapshot(time.Minute)
+	if len(values) != 2 {
+		t.Fatal("Expected one value per topic, got", values)
+	}
+	if values[0].Topic != "cpu" || values[0].Value != 20 {
+		t.Error("Expected the latest value of cpu, got", values[0])
+	}
+	if values[1].Topic != "ram" || values[1].Value != 2048 {
+		t.Error("Unexpected value", values[1])
+	}
+}
+
+func TestLastValueCache_SnapshotRemovesOldValues(t *testing.T) {
+	cache := NewLastValueCache()
+
+	old := telem.NewTelemetry("docker_cgrp_cpu/2cc54a6877a5", 1)
+	old.Time = time.Now().Add(-time.Hour)
+	cache.put(old)
+	cache.put(telem.NewTelemetry("cpu", 1))
+
+	values := cache.Snapshot(time.Minute)
+	if len(values) != 1 || values[0].Topic != "cpu" {
+		t.Error("Expected only the recent value, got", values)
+	}
+	if _, ok := cache.values[old.Topic]; ok {
+		t.Error("Expected the old value to be removed from the cache")
+	}
+}
+
+func TestSnapshotPayload(t *testing.T) {
+	value := telem.NewTelemetry("cpu", 42)
+	value.Time = time.Unix(1600000000, 500000000)
+
+	payload := snapshotPayload([]telem.Telemetry{value})
+	if len(payload) != 1 {
+		t.Fatal("Unexpected payload", payload)
+	}
+	if payload[0]["topic"] != "cpu" || payload[0]["value"] != 42.0 || payload[0]["time"] != 1600000000.5 {
+		t.Error("Unexpected payload", payload[0])
+	}
+}
+
+func TestDaemon_SnapshotOfTickerValues(t *testing.T) {
+	daemon := &Daemon{
+		telemetry: telem.NewTelemetryChannel(),
+		self:      NewSelfStats(),
+		values:    NewLastValueCache(),
+	}
+
+	// the values of tickers pass through the deadline channel of the measurement
+	instrument := ExecInstrument{Command: "echo queue_depth 42"}
+	ticker := NewTelemetryTicker(instrument, daemon.reportChannel(), 10*time.Millisecond, time.Second)
+	go ticker.Run()
+	defer ticker.Stop()
+
+	<-daemon.telemetry.Channel()
+
+	values := daemon.Snapshot(time.Minute)
+	if len(values) != 1 || values[0].Topic != "queue_depth" || values[0].Value != 42 {
+		t.Error("Expected the value of the ticker in the snapshot, got", values)
+	}
+}
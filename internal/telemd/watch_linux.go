@@ -0,0 +1,96 @@
+//go:build linux
+// +build linux
+
+package telemd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// configDebounce is how long WatchConfigFile waits for further changes before it reports a change
+const configDebounce = 500 * time.Millisecond
+
+// WatchConfigFile calls changed whenever the file at the given path is written, created, or replaced by a rename, until
+// done is closed. The directory of the file is watched, so the file does not have to exist yet. Changes are debounced,
+// so that an editor saving the file results in a single call.
+func WatchConfigFile(path string, done <-chan struct{}, changed func()) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return err
+	}
+
+	dir, name := filepath.Dir(path), filepath.Base(path)
+	if _, err := syscall.InotifyAddWatch(fd, dir, syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO|syscall.IN_CREATE); err != nil {
+		_ = syscall.Close(fd)
+		return err
+	}
+	// the non-blocking fd is served by the runtime poller, so that closing the file interrupts a pending read
+	file := os.NewFile(uintptr(fd), "inotify")
+
+	events := make(chan struct{}, 1)
+	go func() {
+		defer close(events)
+		buf := make([]byte, 4096)
+
+		for {
+			n, err := file.Read(buf)
+			if err != nil {
+				return // closed
+			}
+			for _, event := range inotifyNames(buf[:n]) {
+				if event == name {
+					select {
+					case events <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}
+	}()
+
+	go func() {
+		defer file.Close()
+		var debounce <-chan time.Time
+
+		for {
+			select {
+			case <-done:
+				return
+			case _, ok := <-events:
+				if !ok {
+					return
+				}
+				debounce = time.After(configDebounce)
+			case <-debounce:
+				debounce = nil
+				changed()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// inotifyNames returns the file names of the inotify events in the given buffer.
+func inotifyNames(buf []byte) []string {
+	var names []string
+
+	for offset := 0; offset+syscall.SizeofInotifyEvent <= len(buf); {
+		event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		start := offset + syscall.SizeofInotifyEvent
+		end := start + int(event.Len)
+		if end > len(buf) {
+			break
+		}
+		// the name is padded with null bytes
+		names = append(names, strings.TrimRight(string(buf[start:end]), "\x00"))
+		offset = end
+	}
+
+	return names
+}
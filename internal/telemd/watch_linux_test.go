@@ -0,0 +1,59 @@
+//go:build linux
+// +build linux
+
+package telemd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatchConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telemd-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.ini")
+
+	var changes int32
+	done := make(chan struct{})
+	defer close(done)
+
+	if err := WatchConfigFile(path, done, func() { atomic.AddInt32(&changes, 1) }); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "other.ini"), []byte("a=1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// several writes in short succession are reported once
+	for i := 0; i < 3; i++ {
+		if err := ioutil.WriteFile(path, []byte("telemd_period_cpu=1s"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(3 * configDebounce)
+	if n := atomic.LoadInt32(&changes); n != 1 {
+		t.Error("Expected one change, got", n)
+	}
+
+	// editors often replace the file by renaming a temporary file
+	tmp := filepath.Join(dir, ".config.ini.swp")
+	if err := ioutil.WriteFile(tmp, []byte("telemd_period_cpu=2s"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(3 * configDebounce)
+	if n := atomic.LoadInt32(&changes); n != 2 {
+		t.Error("Expected a change after the rename, got", n)
+	}
+}
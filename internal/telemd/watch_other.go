@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package telemd
+
+import "errors"
+
+// WatchConfigFile is not supported on this platform.
+func WatchConfigFile(path string, done <-chan struct{}, changed func()) error {
+	return errors.New("watching the config file is not supported on this platform")
+}
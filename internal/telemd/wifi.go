@@ -0,0 +1,197 @@
+package telemd
+
+import (
+	"github.com/edgerun/telemd/internal/nl80211"
+	"github.com/edgerun/telemd/internal/telem"
+	"log"
+	"os"
+	"time"
+)
+
+// wifiLink is a wireless client interface and the access point it is connected to.
+type wifiLink struct {
+	Interface nl80211.Interface
+	Station   nl80211.StationInfo
+}
+
+// WifiInfo describes the connection of a wireless interface.
+type WifiInfo struct {
+	Device    string `json:"device"`
+	SSID      string `json:"ssid"`
+	BSSID     string `json:"bssid"`
+	Frequency int    `json:"frequency"`
+}
+
+func init() {
+	instruments := []struct {
+		name        string
+		description string
+		new         func(client *nl80211.Client) Instrument
+	}{
+		{"tx_bitrate", "tx bitrate in Mbit/s of each connected wireless interface",
+			func(client *nl80211.Client) Instrument { return &WifiTxBitrateInstrument{client} }},
+		{"rx_bitrate", "rx bitrate in Mbit/s of each connected wireless interface",
+			func(client *nl80211.Client) Instrument { return &WifiRxBitrateInstrument{client} }},
+		{"signal", "signal strength in dBm of each connected wireless interface",
+			func(client *nl80211.Client) Instrument { return &WifiSignalInstrument{client} }},
+		{"wifi", "link quality of each connected wireless interface",
+			func(client *nl80211.Client) Instrument { return &WifiInstrument{client} }},
+	}
+
+	for _, instrument := range instruments {
+		instrument := instrument
+		RegisterInstrument(InstrumentSpec{
+			Name:        instrument.name,
+			Description: instrument.description,
+			Period:      1 * time.Second,
+			Available: func(env *InstrumentEnv) bool {
+				return hasWirelessDevice()
+			},
+			New: func(env *InstrumentEnv) ContextInstrument {
+				if env.Wifi == nil {
+					return nil
+				}
+				return AdaptInstrument(instrument.new(env.Wifi))
+			},
+		})
+	}
+}
+
+// hasWirelessDevice returns true if any network interface has wireless extensions.
+func hasWirelessDevice() bool {
+	devices, err := listFilterDir("/sys/class/net", func(info os.FileInfo) bool {
+		return fileDirExists("/sys/class/net/" + info.Name() + "/wireless")
+	})
+	return err == nil && len(devices) > 0
+}
+
+// readWifiLinks returns the links of all wireless client interfaces that are connected to an access point.
+func readWifiLinks(client *nl80211.Client) ([]wifiLink, error) {
+	interfaces, err := client.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var links []wifiLink
+	for _, iface := range interfaces {
+		if !iface.Station {
+			continue
+		}
+
+		stations, err := client.StationInfo(iface.Index)
+		if err != nil {
+			log.Println("error reading station info of", iface.Name, err)
+			continue
+		}
+		if len(stations) == 0 {
+			continue // not connected
+		}
+
+		links = append(links, wifiLink{Interface: iface, Station: stations[0]})
+	}
+
+	return links, nil
+}
+
+// readWifiNoise returns the noise level in dBm of the channel the given interface currently uses.
+func readWifiNoise(client *nl80211.Client, iface nl80211.Interface) (int, bool) {
+	surveys, err := client.Surveys(iface.Index)
+	if err != nil {
+		return 0, false
+	}
+
+	for _, survey := range surveys {
+		if survey.InUse && survey.HasNoise {
+			return survey.Noise, true
+		}
+	}
+	return 0, false
+}
+
+// wifiInfos returns the connection info of all connected wireless interfaces. It opens a netlink connection for the
+// duration of the call.
+func wifiInfos() ([]WifiInfo, error) {
+	client, err := nl80211.New()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	links, err := readWifiLinks(client)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]WifiInfo, len(links))
+	for i, link := range links {
+		infos[i] = WifiInfo{
+			Device:    link.Interface.Name,
+			SSID:      link.Interface.SSID,
+			BSSID:     link.Station.BSSID.String(),
+			Frequency: link.Interface.Frequency,
+		}
+	}
+	return infos, nil
+}
+
+type WifiTxBitrateInstrument struct {
+	client *nl80211.Client
+}
+type WifiRxBitrateInstrument struct {
+	client *nl80211.Client
+}
+type WifiSignalInstrument struct {
+	client *nl80211.Client
+}
+
+// WifiInstrument reports the noise, tx retries, tx failures, connected time and channel frequency of all connected
+// wireless interfaces.
+type WifiInstrument struct {
+	client *nl80211.Client
+}
+
+func (i WifiTxBitrateInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
+	reportWifiLinks(i.client, channel, "tx_bitrate", func(link wifiLink) float64 { return link.Station.TxBitrate })
+}
+
+func (i WifiRxBitrateInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
+	reportWifiLinks(i.client, channel, "rx_bitrate", func(link wifiLink) float64 { return link.Station.RxBitrate })
+}
+
+func (i WifiSignalInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
+	reportWifiLinks(i.client, channel, "signal", func(link wifiLink) float64 { return float64(link.Station.Signal) })
+}
+
+func (i WifiInstrument) MeasureAndReport(channel telem.TelemetryChannel) {
+	links, err := readWifiLinks(i.client)
+	if err != nil {
+		log.Println("error reading wifi links", err)
+		return
+	}
+
+	for _, link := range links {
+		device := telem.TopicSeparator + link.Interface.Name
+
+		if noise, ok := readWifiNoise(i.client, link.Interface); ok {
+			channel.Put(telem.NewTelemetry("noise"+device, float64(noise)))
+		}
+		channel.Put(telem.NewTelemetry("tx_retries"+device, float64(link.Station.TxRetries)))
+		channel.Put(telem.NewTelemetry("tx_failed"+device, float64(link.Station.TxFailed)))
+		channel.Put(telem.NewTelemetry("connected_time"+device, link.Station.ConnectedTime.Seconds()))
+		if link.Interface.Frequency > 0 {
+			channel.Put(telem.NewTelemetry("channel_freq"+device, float64(link.Interface.Frequency)))
+		}
+	}
+}
+
+func reportWifiLinks(client *nl80211.Client, channel telem.TelemetryChannel, metric string, value func(wifiLink) float64) {
+	links, err := readWifiLinks(client)
+	if err != nil {
+		log.Println("error reading wifi links", err)
+		return
+	}
+
+	for _, link := range links {
+		channel.Put(telem.NewTelemetry(metric+telem.TopicSeparator+link.Interface.Name, value(link)))
+	}
+}
@@ -0,0 +1,408 @@
+package telemd
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigError is an invalid value of a config file. Key is the path of the value, e.g., instruments.cpu.period.
+type ConfigError struct {
+	Key     string
+	Message string
+}
+
+func (err ConfigError) Error() string {
+	return err.Key + ": " + err.Message
+}
+
+// ConfigErrors are all invalid values of a config file.
+type ConfigErrors []ConfigError
+
+func (errs ConfigErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+type yamlKind int
+
+const (
+	yamlString yamlKind = iota
+	yamlBool
+	yamlInt
+	yamlDuration
+	yamlList
+)
+
+func (kind yamlKind) String() string {
+	switch kind {
+	case yamlBool:
+		return "boolean"
+	case yamlInt:
+		return "integer"
+	case yamlDuration:
+		return "duration"
+	case yamlList:
+		return "list"
+	default:
+		return "string"
+	}
+}
+
+// yamlKey is the config key a YAML value is mapped onto.
+type yamlKey struct {
+	key  string
+	kind yamlKind
+}
+
+// yamlKeys are the top-level values of the YAML config
+var yamlKeys = map[string]yamlKey{
+	"nodename": {"telemd_nodename", yamlString},
+	"groups":   {"telemd_groups", yamlList},
+}
+
+// yamlSections are the top-level mappings of the YAML config, except for instruments and nodes
+var yamlSections = map[string]map[string]yamlKey{
+	"config": {
+		"watch":   {"telemd_config_watch", yamlBool},
+		"central": {"telemd_central_config", yamlBool},
+	},
+	"redis": {
+		"url":           {"telemd_redis_url", yamlString},
+		"host":          {"telemd_redis_host", yamlString},
+		"port":          {"telemd_redis_port", yamlInt},
+		"retry_backoff": {"telemd_redis_Retry_backoff", yamlDuration},
+	},
+	"commands": {
+		"keys":     {"telemd_command_keys", yamlList},
+		"max_skew": {"telemd_command_max_skew", yamlDuration},
+	},
+	"mounts": {
+		"proc": {"telemd_proc_mount", yamlString},
+	},
+	"ingest": {
+		"statsd_address":        {"telemd_statsd_address", yamlString},
+		"statsd_flush_interval": {"telemd_statsd_flush_interval", yamlDuration},
+		"socket":                {"telemd_ingest_socket", yamlString},
+	},
+	"docker": {
+		"metadata": {"telemd_docker_metadata", yamlBool},
+		"socket":   {"telemd_docker_socket", yamlString},
+	},
+	"kubelet": {
+		"metadata":         {"telemd_kubelet_metadata", yamlBool},
+		"url":              {"telemd_kubelet_url", yamlString},
+		"token_file":       {"telemd_kubelet_token_file", yamlString},
+		"insecure":         {"telemd_kubelet_insecure", yamlBool},
+		"refresh_interval": {"telemd_kubelet_refresh_interval", yamlDuration},
+	},
+	"events": {
+		"containers":             {"telemd_container_events", yamlBool},
+		"containers_interval":    {"telemd_container_events_interval", yamlDuration},
+		"default_iface_interval": {"telemd_default_iface_interval", yamlDuration},
+	},
+}
+
+// yamlInstrumentKeys are the values of the instruments mapping, besides the mappings of the individual instruments
+var yamlInstrumentKeys = map[string]yamlKey{
+	"enable":             {"telemd_instruments_enable", yamlList},
+	"disable":            {"telemd_instruments_disable", yamlList},
+	"timeout":            {"telemd_instrument_timeout", yamlDuration},
+	"discovery_interval": {"telemd_device_discovery_interval", yamlDuration},
+}
+
+// yamlInstrumentOptions are the options of individual instruments, besides period and timeout
+var yamlInstrumentOptions = map[string]map[string]yamlKey{
+	"net": {
+		"devices": {"telemd_net_devices", yamlList},
+		"exclude": {"telemd_net_devices_exclude", yamlList},
+	},
+	"disk": {
+		"devices": {"telemd_disk_devices", yamlList},
+		"exclude": {"telemd_disk_devices_exclude", yamlList},
+	},
+	"textfile": {
+		"dir":     {"telemd_textfile_dir", yamlString},
+		"max_age": {"telemd_textfile_max_age", yamlDuration},
+	},
+}
+
+// yamlConfig is a YAML config file flattened into config keys, e.g.:
+//
+//	redis:
+//	  url: redis://192.168.0.10
+//	instruments:
+//	  disable: [wifi]
+//	  cpu:
+//	    period: 250ms
+//	  net:
+//	    devices: ["eth*"]
+//	  exec:
+//	    queues:
+//	      command: /opt/queues.sh
+//	nodes:
+//	  pi-01:
+//	    instruments:
+//	      disk:
+//	        devices: [mmcblk0]
+//
+// The values in nodes/<nodename> take precedence over the global ones, like the node sections of the ini file.
+type yamlConfig struct {
+	global map[string]string
+	nodes  map[string]map[string]string
+}
+
+// readYamlConfig reads the YAML config file at the given path. If the file contains invalid values, it returns
+// ConfigErrors naming all of them.
+func readYamlConfig(path string) (*yamlConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseYamlConfig(data)
+}
+
+func parseYamlConfig(data []byte) (*yamlConfig, error) {
+	var doc map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	cfg := &yamlConfig{
+		global: make(map[string]string),
+		nodes:  make(map[string]map[string]string),
+	}
+	var errs ConfigErrors
+
+	if nodes, ok := doc["nodes"]; ok {
+		delete(doc, "nodes")
+
+		mapping, ok := nodes.(map[interface{}]interface{})
+		if !ok && nodes != nil {
+			errs = append(errs, ConfigError{"nodes", "expected a mapping of node names"})
+		}
+		for _, node := range sortedEntries(mapping) {
+			path := "nodes." + node.name
+			section, ok := node.value.(map[interface{}]interface{})
+			if !ok {
+				errs = append(errs, ConfigError{path, "expected a mapping"})
+				continue
+			}
+			if _, ok := section["nodename"]; ok {
+				errs = append(errs, ConfigError{path + ".nodename", "cannot be set per node"})
+				delete(section, "nodename")
+			}
+			cfg.nodes[node.name] = make(map[string]string)
+			readYamlSection(path+".", section, cfg.nodes[node.name], &errs)
+		}
+	}
+
+	readYamlSection("", doc, cfg.global, &errs)
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return cfg, nil
+}
+
+// readYamlSection flattens the top-level values of the config, or of a node, into values.
+func readYamlSection(prefix string, doc map[interface{}]interface{}, values map[string]string, errs *ConfigErrors) {
+	for _, entry := range sortedEntries(doc) {
+		name, value := entry.name, entry.value
+		path := prefix + name
+
+		if key, ok := yamlKeys[name]; ok {
+			setYamlValue(path, value, key, values, errs)
+			continue
+		}
+		if name == "instruments" {
+			readYamlInstruments(path, value, values, errs)
+			continue
+		}
+		keys, ok := yamlSections[name]
+		if !ok {
+			*errs = append(*errs, ConfigError{path, "unknown key"})
+			continue
+		}
+		readYamlMapping(path, value, keys, values, errs)
+	}
+}
+
+// readYamlMapping flattens a mapping whose values are described by the given keys.
+func readYamlMapping(path string, value interface{}, keys map[string]yamlKey, values map[string]string, errs *ConfigErrors) {
+	mapping, ok := value.(map[interface{}]interface{})
+	if !ok {
+		if value != nil {
+			*errs = append(*errs, ConfigError{path, "expected a mapping"})
+		}
+		return
+	}
+
+	for _, entry := range sortedEntries(mapping) {
+		key, ok := keys[entry.name]
+		if !ok {
+			*errs = append(*errs, ConfigError{path + "." + entry.name, "unknown key"})
+			continue
+		}
+		setYamlValue(path+"."+entry.name, entry.value, key, values, errs)
+	}
+}
+
+func readYamlInstruments(path string, value interface{}, values map[string]string, errs *ConfigErrors) {
+	mapping, ok := value.(map[interface{}]interface{})
+	if !ok {
+		if value != nil {
+			*errs = append(*errs, ConfigError{path, "expected a mapping"})
+		}
+		return
+	}
+
+	for _, entry := range sortedEntries(mapping) {
+		name, value := entry.name, entry.value
+		instrumentPath := path + "." + name
+
+		if key, ok := yamlInstrumentKeys[name]; ok {
+			setYamlValue(instrumentPath, value, key, values, errs)
+			if key.kind == yamlList {
+				checkInstrumentNames(instrumentPath, values[key.key], errs)
+			}
+			continue
+		}
+		if name == "exec" {
+			readYamlExecInstruments(instrumentPath, value, values, errs)
+			continue
+		}
+		if _, ok := LookupInstrument(name); !ok {
+			*errs = append(*errs, ConfigError{instrumentPath, "unknown instrument"})
+			continue
+		}
+
+		keys := map[string]yamlKey{
+			"period":  {"telemd_period_" + name, yamlDuration},
+			"timeout": {"telemd_timeout_" + name, yamlDuration},
+		}
+		for option, key := range yamlInstrumentOptions[name] {
+			keys[option] = key
+		}
+		readYamlMapping(instrumentPath, value, keys, values, errs)
+	}
+}
+
+// readYamlExecInstruments flattens the mapping of exec instrument names to their command, period and timeout.
+func readYamlExecInstruments(path string, value interface{}, values map[string]string, errs *ConfigErrors) {
+	mapping, ok := value.(map[interface{}]interface{})
+	if !ok {
+		if value != nil {
+			*errs = append(*errs, ConfigError{path, "expected a mapping of exec instrument names"})
+		}
+		return
+	}
+
+	var names []string
+	for _, entry := range sortedEntries(mapping) {
+		name := entry.name
+		prefix := "telemd_exec_" + name
+		keys := map[string]yamlKey{
+			"command": {prefix + "_command", yamlString},
+			"period":  {prefix + "_period", yamlDuration},
+			"timeout": {prefix + "_timeout", yamlDuration},
+		}
+		readYamlMapping(path+"."+name, entry.value, keys, values, errs)
+		names = append(names, name)
+	}
+	values["telemd_exec_instruments"] = strings.Join(names, " ")
+}
+
+// checkInstrumentNames reports the names of the list that are not registered instruments. exec instruments are not
+// checked, as they may be defined by another layer of the config.
+func checkInstrumentNames(path string, list string, errs *ConfigErrors) {
+	for _, name := range strings.Fields(list) {
+		if strings.HasPrefix(name, "exec_") {
+			continue
+		}
+		if _, ok := LookupInstrument(name); !ok {
+			*errs = append(*errs, ConfigError{path, "unknown instrument " + strconv.Quote(name)})
+		}
+	}
+}
+
+// setYamlValue validates the value against the kind of the key, and sets it in the format of the environment.
+func setYamlValue(path string, value interface{}, key yamlKey, values map[string]string, errs *ConfigErrors) {
+	s, err := formatYamlValue(value, key.kind)
+	if err != nil {
+		*errs = append(*errs, ConfigError{path, err.Error()})
+		return
+	}
+	values[key.key] = s
+}
+
+func formatYamlValue(value interface{}, kind yamlKind) (string, error) {
+	switch value.(type) {
+	case nil, map[interface{}]interface{}:
+		return "", fmt.Errorf("expected a %s", kind)
+	case []interface{}:
+		if kind != yamlList {
+			return "", fmt.Errorf("expected a %s", kind)
+		}
+	}
+
+	if kind == yamlList {
+		items, ok := value.([]interface{})
+		if !ok {
+			// a single string of space separated items, as in the ini file
+			return fmt.Sprint(value), nil
+		}
+		fields := make([]string, len(items))
+		for i, item := range items {
+			switch item.(type) {
+			case nil, map[interface{}]interface{}, []interface{}:
+				return "", fmt.Errorf("expected a list of strings")
+			}
+			fields[i] = fmt.Sprint(item)
+		}
+		return strings.Join(fields, " "), nil
+	}
+
+	s := fmt.Sprint(value)
+	var err error
+	switch kind {
+	case yamlBool:
+		_, err = strconv.ParseBool(s)
+	case yamlInt:
+		_, err = strconv.ParseInt(s, 10, 64)
+	case yamlDuration:
+		var duration time.Duration
+		if duration, err = time.ParseDuration(s); err == nil && duration <= 0 {
+			// durations are periods, intervals, or timeouts, a ticker panics on a non-positive period
+			return "", fmt.Errorf("%s must be positive, got %s", kind, strconv.Quote(s))
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid %s %s", kind, strconv.Quote(s))
+	}
+	return s, nil
+}
+
+type yamlEntry struct {
+	name  string
+	value interface{}
+}
+
+// sortedEntries returns the entries of the mapping ordered by name, so that errors are reported in a stable order.
+func sortedEntries(mapping map[interface{}]interface{}) []yamlEntry {
+	entries := make([]yamlEntry, 0, len(mapping))
+	for key, value := range mapping {
+		entries = append(entries, yamlEntry{fmt.Sprint(key), value})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+	return entries
+}
@@ -0,0 +1,155 @@
+package telemd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseYamlConfig(t *testing.T) {
+	cfg, err := parseYamlConfig([]byte(`
+groups: [pis, site-a]
+redis:
+  host: 192.168.0.10
+  port: 6380
+instruments:
+  disable: [wifi]
+  cpu:
+    period: 250ms
+  net:
+    devices: ["eth*", "wlan0"]
+    exclude: veth*
+  exec:
+    queues:
+      command: /opt/queues.sh
+      timeout: 2s
+nodes:
+  pi-01:
+    docker:
+      metadata: true
+`))
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	expected := map[string]string{
+		"telemd_groups":              "pis site-a",
+		"telemd_redis_host":          "192.168.0.10",
+		"telemd_redis_port":          "6380",
+		"telemd_instruments_disable": "wifi",
+		"telemd_period_cpu":          "250ms",
+		"telemd_net_devices":         "eth* wlan0",
+		"telemd_net_devices_exclude": "veth*",
+		"telemd_exec_instruments":    "queues",
+		"telemd_exec_queues_command": "/opt/queues.sh",
+		"telemd_exec_queues_timeout": "2s",
+	}
+	if !reflect.DeepEqual(cfg.global, expected) {
+		t.Error("Unexpected values", cfg.global)
+	}
+	if !reflect.DeepEqual(cfg.nodes["pi-01"], map[string]string{"telemd_docker_metadata": "true"}) {
+		t.Error("Unexpected node values", cfg.nodes["pi-01"])
+	}
+}
+
+func TestParseYamlConfig_Errors(t *testing.T) {
+	_, err := parseYamlConfig([]byte(`
+redis:
+  ur: redis://localhost
+instruments:
+  enable: [cpu, cpuu]
+  cpu:
+    period: 10
+  net:
+    devices: {eth0: true}
+nodes:
+  pi-01:
+    nodename: pi-02
+`))
+
+	var errs ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatal("Expected ConfigErrors, got", err)
+	}
+
+	expected := ConfigErrors{
+		{"nodes.pi-01.nodename", "cannot be set per node"},
+		{"instruments.cpu.period", `invalid duration "10"`},
+		{"instruments.enable", `unknown instrument "cpuu"`},
+		{"instruments.net.devices", "expected a list"},
+		{"redis.ur", "unknown key"},
+	}
+	if !reflect.DeepEqual(errs, expected) {
+		t.Error("Unexpected errors", errs)
+	}
+}
+
+func TestParseYamlConfig_NonPositiveDurations(t *testing.T) {
+	_, err := parseYamlConfig([]byte(`
+instruments:
+  cpu:
+    period: 0s
+events:
+  default_iface_interval: -1s
+`))
+
+	var errs ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatal("Expected ConfigErrors, got", err)
+	}
+
+	expected := ConfigErrors{
+		{"events.default_iface_interval", `duration must be positive, got "-1s"`},
+		{"instruments.cpu.period", `duration must be positive, got "0s"`},
+	}
+	if !reflect.DeepEqual(errs, expected) {
+		t.Error("Unexpected errors", errs)
+	}
+}
+
+func TestLoadConfig_Yaml(t *testing.T) {
+	hostname, _ := os.Hostname()
+
+	file, err := ioutil.TempFile("", "telemd-config-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	_, _ = file.WriteString(`
+instruments:
+  procs:
+    period: 2s
+  ram:
+    period: 3s
+  load:
+    period: 3s
+nodes:
+  ` + hostname + `:
+    instruments:
+      ram:
+        period: 4s
+`)
+	_ = file.Close()
+
+	_ = os.Setenv("telemd_period_load", "5s")
+	defer os.Unsetenv("telemd_period_load")
+
+	cfg, err := LoadConfig(file.Name())
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	if cfg.Instruments.Periods["procs"] != 2*time.Second {
+		t.Error("Expected period of the global config, got", cfg.Instruments.Periods["procs"])
+	}
+	if cfg.Instruments.Periods["ram"] != 4*time.Second {
+		t.Error("Expected period of the node config, got", cfg.Instruments.Periods["ram"])
+	}
+	if cfg.Instruments.Periods["load"] != 5*time.Second {
+		t.Error("Expected period of the os environment, got", cfg.Instruments.Periods["load"])
+	}
+}